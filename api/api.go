@@ -10,17 +10,43 @@ import (
 
 // API private struct for shared state.
 type API struct {
-	db          *db.OurDB
-	searchPage  *template.Template
-	namesPage   *template.Template
-	liftersPage *template.Template
-	aboutPage   *template.Template
+	db            db.Store
+	searchPage    *template.Template
+	namesPage     *template.Template
+	liftersPage   *template.Template
+	aboutPage     *template.Template
+	meetsPage     *template.Template
+	meetPage      *template.Template
+	rankingsPage  *template.Template
+	comparePage   *template.Template
+	recordsPage   *template.Template
+	hometownsPage *template.Template
+	hometownPage  *template.Template
+	// renderError is called when a page template fails
+	renderError func(page string, err error)
+}
+
+// OnRenderError registers f to be called whenever a page fails to render.
+func (a *API) OnRenderError(f func(page string, err error)) {
+	a.renderError = f
+}
+
+// render executes a page template, reporting failures to the client, the
+// log and the render error hook.
+func (a API) render(w http.ResponseWriter, t *template.Template, data interface{}) {
+	if err := t.Execute(w, data); err != nil {
+		log.Printf("%v\n", err)
+		if a.renderError != nil {
+			a.renderError(t.Name(), err)
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
 
 // NewAPI returns an api that can be used to process http requests
-func NewAPI(db *db.OurDB) *API {
+func NewAPI(db db.Store) *API {
 	// results
-	lifts := template.Must(template.New("liftingResults").Parse(liftingResults))
+	lifts := template.Must(template.New("liftingResults").Funcs(template.FuncMap{"progressionChart": progressionChart}).Parse(liftingResults))
 	lifts.Parse(css)
 	lifts.Parse(resultsTable)
 
@@ -38,11 +64,43 @@ func NewAPI(db *db.OurDB) *API {
 	about.Parse(css)
 	about.Parse(aboutPage)
 
-	return &API{db: db, searchPage: search, namesPage: names, liftersPage: lifts, aboutPage: about}
+	meets := template.Must(template.New("meets").Parse(liftingResults))
+	meets.Parse(css)
+	meets.Parse(meetsPage)
+
+	meet := template.Must(template.New("meet").Parse(liftingResults))
+	meet.Parse(css)
+	meet.Parse(meetPage)
+
+	rankings := template.Must(template.New("rankings").Parse(liftingResults))
+	rankings.Parse(css)
+	rankings.Parse(rankingsPage)
+
+	compare := template.Must(template.New("compare").Funcs(template.FuncMap{"comparisonCharts": comparisonCharts, "svg": svg}).Parse(liftingResults))
+	compare.Parse(css)
+	compare.Parse(comparePage)
+
+	records := template.Must(template.New("records").Parse(liftingResults))
+	records.Parse(css)
+	records.Parse(recordsPage)
+
+	hometowns := template.Must(template.New("hometowns").Parse(liftingResults))
+	hometowns.Parse(css)
+	hometowns.Parse(hometownsPage)
+
+	hometown := template.Must(template.New("hometown").Funcs(template.FuncMap{"percentOf": percentOf}).Parse(liftingResults))
+	hometown.Parse(css)
+	hometown.Parse(hometownPage)
+
+	return &API{db: db, searchPage: search, namesPage: names, liftersPage: lifts, aboutPage: about, meetsPage: meets, meetPage: meet, rankingsPage: rankings, comparePage: compare, recordsPage: records, hometownsPage: hometowns, hometownPage: hometown}
 }
 
 // Search parses query parameters for name and returns a list of names
 func (a API) Search(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		a.LiftersJSON(w, r)
+		return
+	}
 	if r.Method == "GET" {
 		r.ParseForm()
 		// this needs validation! should be characters, maybe a digit, spaces
@@ -66,33 +124,28 @@ func (a API) Search(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := a.namesPage.Execute(w, found); err != nil {
-			log.Printf("%v\n", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		a.render(w, a.namesPage, found)
 	}
 }
 
 // SearchForm is the landing page and displays the search form.
 func (a API) SearchForm(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		if err := a.searchPage.Execute(w, nil); err != nil {
-			log.Printf("%v\n", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		a.render(w, a.searchPage, nil)
 	}
 }
 
 func (a API) About(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		if err := a.aboutPage.Execute(w, nil); err != nil {
-			log.Printf("%v\n", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		a.render(w, a.aboutPage, nil)
 	}
 }
 
 func (a API) Results(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		a.LifterResultsJSON(w, r)
+		return
+	}
 	if r.Method == "GET" {
 		names, ok := r.URL.Query()["name"]
 		if !ok || len(names) != 1 {
@@ -115,10 +168,7 @@ func (a API) Results(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		// lifts
-		if err := a.liftersPage.Execute(w, found); err != nil {
-			log.Printf("%v\n", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		a.render(w, a.liftersPage, found)
 	}
 }
 
@@ -140,7 +190,10 @@ var aboutPage = `{{ define "content"}}
   make it easier to reconcile whether the USAW has incorrect data versus this
   site, every result links back to the original data from the USAW site. This
   link will show up in the <span style="font-weight: bold">MEET (USAW
-  LINK)</span> column of the results table.
+  LINK)</span> column of the results table. Results with numbers that can't
+  be right, like attempts that go down or a bodyweight outside the weight
+  class, are labelled in the <span style="font-weight: bold">DATA</span>
+  column.
 	</p>
 </article>
 {{ end }}`
@@ -179,7 +232,7 @@ var searchNamesResults = `{{ define "content" }}
 			{{ range .Lifters }}
 				<a href="results?name={{ .Name }}&hometown={{ .Hometown }}">
 					<div class="uk-card">
-						<h4 class="uk-card-title">{{ .Name }} - {{ .Hometown }}</h3>
+						<h4 class="uk-card-title">{{ .Name }} - {{ if .Hometowns }}{{ range $i, $h := .Hometowns }}{{ if $i }} / {{ end }}{{ $h }}{{ end }}{{ else }}{{ .Hometown }}{{ end }}</h3>
 					</div>
 				</a>
 			{{ end }}
@@ -191,6 +244,9 @@ var searchNamesResults = `{{ define "content" }}
 		<div>
 			<ul class="uk-pagination uk-margin">
 			{{ range .Pages }}
+				{{ if .Gap }}
+					<li class="uk-disabled"><span>&hellip;</span></li>
+				{{ end }}
 				{{ if (eq .Display $.Current)}}
 					<li class="uk-active">
 				{{ else }}
@@ -219,10 +275,14 @@ var resultsTable = `{{ define "content" }}
 {{ end}}
 {{ if .Results }}
 <article class="uk-article">
-	<h1 class="uk-article-title">{{ .Lifter }} / {{ .Hometown }}</h1>
+	<h1 class="uk-article-title">{{ .Lifter }} / <a href="/hometown?name={{ .Hometown }}">{{ .Hometown }}</a></h1>
+	{{ if gt (len .Hometowns) 1 }}
+	<p class="uk-text-meta">Has competed from {{ range $i, $h := .Hometowns }}{{ if $i }} / {{ end }}<a href="/hometown?name={{ $h }}">{{ $h }}</a>{{ end }}</p>
+	{{ end }}
 	<h3>Links</h3>
 	<ul class="uk-list">
 		<li><a rel="noopener noreferrer" target="_blank" href="https://www.iwf.net/new_bw/results_by_events/?athlete_name={{ .IWFLastName }}+{{ .IWFFirstName }}&athlete_gender=all&athlete_nation=USA">Search for IWF results</a></li>
+		<li>Download results as <a href="/results.csv?name={{ .Lifter }}&hometown={{ .Hometown }}">CSV</a> or <a href="/results.json?name={{ .Lifter }}&hometown={{ .Hometown }}">JSON</a></li>
 	</ul>
 	<h3>Statistics</h3>
 	<p class="uk-text-muted">Computed from USAW competition results. See <a href="/about">about</a> to learn about data problems.</p>
@@ -232,6 +292,7 @@ var resultsTable = `{{ define "content" }}
 				<li>Best CJ: {{ .BestCJ }} kg</li>
 				<li>Best Snatch: {{ .BestSN }} kg </li>
 				<li>Best Total: {{ .BestTotal }} kg</li>
+				<li>Best Sinclair: {{ .BestSinclair }}</li>
 				<ul>
 		</div>
 		<div>
@@ -242,8 +303,80 @@ var resultsTable = `{{ define "content" }}
 			</ul>
 		</div>
 	</div>
+	{{ with .Attempts }}
+	<h3>Attempts</h3>
+	<p class="uk-text-muted">Openers are compared with the best made at earlier meets. A bomb out is a meet where every attempt at a lift was missed.</p>
+	<div class="uk-overflow-auto">
+		<table class="uk-table uk-table-divider uk-table-small">
+			<thead>
+				<tr>
+					<th class="uk-table-expand"></th>
+					<th>Snatch</th>
+					<th>Clean & Jerk</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{ range $i, $sn := .Snatch.Attempts }}
+				{{ $cj := index $.Attempts.CleanJerk.Attempts $i }}
+				<tr>
+					<td>Attempt {{ $sn.Attempt }} made</td>
+					<td data-label="Snatch">{{ $sn.Percent }}% ({{ $sn.Made }}/{{ $sn.Taken }})</td>
+					<td data-label="Clean & Jerk">{{ $cj.Percent }}% ({{ $cj.Made }}/{{ $cj.Taken }})</td>
+				</tr>
+				{{ end }}
+				<tr>
+					<td>Average jump</td>
+					<td data-label="Snatch">{{ .Snatch.AvgJump }} kg</td>
+					<td data-label="Clean & Jerk">{{ .CleanJerk.AvgJump }} kg</td>
+				</tr>
+				<tr>
+					<td>Average opener</td>
+					<td data-label="Snatch">{{ .Snatch.AvgOpenerPercent }}% of previous best</td>
+					<td data-label="Clean & Jerk">{{ .CleanJerk.AvgOpenerPercent }}% of previous best</td>
+				</tr>
+				<tr>
+					<td>Bomb outs</td>
+					<td data-label="Snatch">{{ .Snatch.BombOuts }} ({{ .Snatch.BombOutPercent }}%)</td>
+					<td data-label="Clean & Jerk">{{ .CleanJerk.BombOuts }} ({{ .CleanJerk.BombOutPercent }}%)</td>
+				</tr>
+			</tbody>
+		</table>
+	</div>
+	<p>Meets without a total: {{ .BombOuts }} of {{ .Meets }} ({{ .BombOutPercent }}%)</p>
+	{{ end }}
+	<h3>Progression</h3>
+	<p class="uk-text-muted">Hover over a point for the meet. <a href="/results/chart.svg?name={{ .Lifter }}&hometown={{ .Hometown }}">Open chart</a></p>
+	<div class="uk-margin">
+		{{ progressionChart . }}
+	</div>
+	<h3>PR history</h3>
+	<p class="uk-text-muted">Class PRs are bests within a weight class that weren't all time bests.</p>
+	<div class="uk-overflow-auto">
+		<table class="uk-table uk-table-divider uk-table-small">
+			<thead>
+				<tr>
+					<th>Date</th>
+					<th class="uk-table-expand">Meet</th>
+					<th>Lift</th>
+					<th>kg</th>
+					<th>Gain</th>
+				</tr>
+			</thead>
+			<tbody>
+			{{ range .PRHistory }}
+				<tr>
+					<td data-label="Date">{{ .Date }}</td>
+					<td data-label="Meet">{{ .MeetName }}</td>
+					<td data-label="Lift">{{ .Lift }}{{ if .Weightclass }} ({{ .Weightclass }}){{ end }}</td>
+					<td data-label="kg">{{ .Value }}</td>
+					<td data-label="Gain">{{ if .First }}first{{ else }}+{{ .Delta }}{{ end }}</td>
+				</tr>
+			{{ end }}
+			</tbody>
+		</table>
+	</div>
 	<h3>USAW Competitions</h3>
-	<p class="uk-text-muted">*Bests are bolded</p>
+	<p class="uk-text-muted">*Bests are bolded. Results USAW published with inconsistent numbers are labelled under Data, hover for details.</p>
 	<div class="uk-overflow-auto">
 		<table class="uk-table uk-table-divider uk-table-hover">
 			<thead>
@@ -258,10 +391,13 @@ var resultsTable = `{{ define "content" }}
 					<th>CJ2</th>
 					<th>CJ3</th>
 					<th>Total</th>
+					<th>Sinclair</th>
 					<th class="uk-text-nowrap">Best SN</th>
 					<th class="uk-text-nowrap">Best CJ</th>
 					<th class="uk-text-nowrap">SNs/3</th>
 					<th class="uk-text-nowrap">CJs/3</th>
+					<th>PRs</th>
+					<th>Data</th>
 				</tr>
 			</thead>
 			<tbody>
@@ -272,7 +408,7 @@ var resultsTable = `{{ define "content" }}
 				<tr>
 				{{ end }}
 					<td data-label="Meet Date">{{ .Date }}</td>
-					<td data-label="Name"><a rel="noopener noreferrer" target="_blank" href="{{ .URL }}&isPopup=&Tab=Results">{{ .MeetName }}</a></td>
+					<td data-label="Name"><a rel="noopener noreferrer" target="_blank" href="{{ .URL }}&isPopup=&Tab=Results">{{ .MeetName }}</a> (<a href="meet?name={{ .MeetName }}&date={{ .Date }}">all results</a>)</td>
 					<td data-label="Weight Class">{{ .Weightclass }} @ {{ .CompetitionWeight }}</td>
 					<td data-label="SN1">{{ .SN1 }}</td>
 					<td data-label="SN2">{{ .SN2 }}</td>
@@ -281,10 +417,13 @@ var resultsTable = `{{ define "content" }}
 					<td data-label="CJ2">{{ .CJ2 }}</td>
 					<td data-label="CJ3">{{ .CJ3 }}</td>
 					<td data-label="Total">{{ .Total }}</td>
+					<td data-label="Sinclair">{{ .Sinclair }}</td>
 					<td data-label="Best Snatch">{{ .BestSN }}</td>
 					<td data-label="Best CJ">{{ .BestCJ }}</td>
 					<td data-label="# Snatches made">{{ .SNSMade }}</td>
 					<td data-label="# CJs made">{{ .CJSMade }}</td>
+					<td data-label="PRs">{{ range .PRs }}<span class="uk-label uk-label-success" title="{{ if .First }}first result{{ else }}previous {{ .Previous }} kg{{ end }}">{{ if .Weightclass }}class {{ end }}{{ .Lift }}{{ if not .First }} +{{ .Delta }}{{ end }}</span> {{ end }}</td>
+					<td data-label="Data">{{ range .Flags }}<span class="uk-label uk-label-warning" title="{{ .Description }}">{{ . }}</span> {{ end }}</td>
 				</tr>
 				{{ end }}
 			</tbody>
@@ -329,14 +468,67 @@ var landingPage = `<!doctype html>
 				<p class="">Search USA Weightlifting data from 2012 onward. See <a href="/about">about</a> to learn more!</p>
 				<div class="uk-margin" uk-margin>
 					<form class="uk-form" action="/search" method="GET" uk-form>
-						<input class="uk-input uk-form-width-large" name="name" type="search" placeholder="Find a lifter by name" required minlength=3 autofocus>
+						<input id="search" class="uk-input uk-form-width-large" name="name" type="search" placeholder="Find a lifter by name" required minlength=3 autofocus autocomplete="off" aria-controls="suggestions">
 						<button class="uk-button uk-button-default" type="submit" value="Search">Search</button>
 					</form>
+					<ul id="suggestions" class="uk-list uk-list-divider uk-card uk-card-default uk-card-small uk-card-body uk-form-width-large" aria-live="polite" hidden></ul>
 				</div>
 			</div>
 		</div>
 		<!-- UIkit JS -->
 		<script src="https://cdnjs.cloudflare.com/ajax/libs/uikit/3.0.3/js/uikit.min.js"></script>
 		<script src="https://cdnjs.cloudflare.com/ajax/libs/uikit/3.0.3/js/uikit-icons.min.js"></script>
+		<script>
+		// suggest lifters while typing, the form still works without this
+		(function() {
+			var input = document.getElementById("search");
+			var list = document.getElementById("suggestions");
+			if (!input || !list || !window.fetch) {
+				return;
+			}
+			var timer, latest = "";
+			function show(suggestions) {
+				list.innerHTML = "";
+				suggestions.forEach(function(s) {
+					var a = document.createElement("a");
+					a.href = "/results?name=" + encodeURIComponent(s.name) + "&hometown=" + encodeURIComponent(s.hometown);
+					a.textContent = s.name;
+					var meta = document.createElement("span");
+					meta.className = "uk-text-muted uk-text-small";
+					meta.textContent = " " + s.hometown + ", " + s.meets + (s.meets == 1 ? " meet" : " meets");
+					var li = document.createElement("li");
+					li.appendChild(a);
+					li.appendChild(meta);
+					list.appendChild(li);
+				});
+				list.hidden = suggestions.length == 0;
+			}
+			input.addEventListener("input", function() {
+				clearTimeout(timer);
+				var q = input.value.trim();
+				latest = q;
+				if (q.length < 2) {
+					show([]);
+					return;
+				}
+				timer = setTimeout(function() {
+					fetch("/api/v1/suggest?q=" + encodeURIComponent(q), {headers: {"Accept": "application/json"}})
+						.then(function(resp) { return resp.ok ? resp.json() : {suggestions: []}; })
+						.then(function(body) {
+							// answers can arrive out of order
+							if (q == latest) {
+								show(body.suggestions);
+							}
+						})
+						.catch(function() { show([]); });
+				}, 150);
+			});
+			input.addEventListener("keydown", function(e) {
+				if (e.key == "Escape") {
+					show([]);
+				}
+			});
+		})();
+		</script>
 	</body>
 </html>`
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+var fixtures db.Store
+
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	store, err := db.LoadFixtures("../db/testdata/fixtures.jsonl")
+	if err != nil {
+		panic(err)
+	}
+	fixtures = store
+	code := m.Run()
+	store.Close()
+	os.Exit(code)
+}
+
+func get(t *testing.T, handler http.HandlerFunc, url, accept string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", url, nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestSearchHTML(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.Search, "/search?name=kyle+brown", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Found 2 matching lifters")
+	assert.Contains(t, w.Body.String(), "Kyle Brown - Reno, NV")
+}
+
+func TestResultsHTML(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.Results, "/results?name=Chris+Wolfe&hometown=Austin,+TX", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Chris Wolfe / Austin, TX")
+	assert.Contains(t, w.Body.String(), "Best Total: 192 kg")
+	assert.Contains(t, w.Body.String(), "Meets without a total: 0 of 3")
+
+	w = get(t, a.Results, "/results?name=Chris+Wolfe", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestLiftersJSON(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.Search, "/search?name=steph&page=2", "application/json")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var found db.LiftersResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, int64(56), found.Total)
+	assert.Equal(t, int64(2), found.Current)
+	assert.Len(t, found.Lifters, 6)
+}
+
+func TestLiftersJSONCursor(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.LiftersJSON, "/api/v1/lifters?name=steph", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var first db.LiftersResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &first))
+	assert.Len(t, first.Lifters, 50)
+	assert.NotEqual(t, "", first.NextCursor)
+
+	w = get(t, a.LiftersJSON, "/api/v1/lifters?name=steph&cursor="+first.NextCursor, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var second db.LiftersResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &second))
+	assert.Len(t, second.Lifters, 6)
+	assert.Equal(t, "", second.NextCursor)
+
+	w = get(t, a.LiftersJSON, "/api/v1/lifters?name=steph&cursor=garbage", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = get(t, a.LiftersJSON, "/api/v1/lifters?name=steph&page=2&cursor="+first.NextCursor, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSuggestJSON(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.SuggestJSON, "/api/v1/suggest?q=mattie+ro", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
+
+	var found db.SuggestResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, "mattie ro", found.Query)
+	assert.Equal(t, []db.Suggestion{{Name: "Mattie Rogers", Hometown: "Orlando, FL", Meets: 3}}, found.Suggestions)
+
+	w = get(t, a.SuggestJSON, "/api/v1/suggest?q=steph&limit=2", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Len(t, found.Suggestions, 2)
+
+	w = get(t, a.SuggestJSON, "/api/v1/suggest?q=steph&limit=500", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHometowns(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.Hometowns, "/hometowns?name=springfield", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `href="hometown?name=Springfield%2c%20IL"`)
+
+	w = get(t, a.Hometowns, "/hometowns?page=0", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get(t, a.Hometown, "/hometown?name=Austin,+TX", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `href="results?name=Chris%20Wolfe&hometown=Austin%2c%20TX"`)
+	assert.Contains(t, w.Body.String(), "2017 - 2018")
+
+	w = get(t, a.Hometown, "/hometown", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHometownJSON(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.Hometown, "/hometown?name=Austin,+TX", "application/json")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var found db.HometownResults
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, "Austin, TX", found.Name)
+	assert.Len(t, found.Lifters, 1)
+	assert.Len(t, found.Attendance, 2)
+
+	w = get(t, a.HometownJSON, "/api/v1/hometown?name=Nowhere", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = get(t, a.HometownsJSON, "/api/v1/hometowns", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var all db.HometownsResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &all))
+	assert.Equal(t, int64(11), all.Total)
+}
+
+func TestLifterResultsJSON(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.LifterResultsJSON, "/api/v1/lifters/results?name=Mattie+Rogers&hometown=Orlando,+FL", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var found db.ResultsSummary
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, "Mattie Rogers", found.Lifter)
+	assert.Len(t, found.Results, 3)
+	assert.Equal(t, "248", found.BestTotal.String())
+
+	w = get(t, a.LifterResultsJSON, "/api/v1/lifters/results?name=Nobody&hometown=Nowhere", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMeetJSON(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.Meet, "/meet?name=USA+Weightlifting+National+Championships&date=2018-06-20", "application/json")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var found db.MeetResults
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.NotEmpty(t, found.WeightClasses)
+	for _, wc := range found.WeightClasses {
+		assert.Equal(t, 1, wc.Entries[0].Place, "first entry in %v should be placed first", wc.Weightclass)
+	}
+
+	w = get(t, a.MeetJSON, "/api/v1/meet?name=Nope&date=2018-06-20", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRankingsJSON(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.RankingsJSON, "/api/v1/rankings?gender=female&year=2018&metric=snatch", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var found db.RankingsResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, "Mattie Rogers", found.Rankings[0].Lifter)
+	assert.Equal(t, "108", found.Rankings[0].Value.String())
+
+	w = get(t, a.Rankings, "/rankings?gender=female", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Mattie Rogers")
+}
+
+func TestResultsChart(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.ResultsChart, "/results/chart.svg?name=Chris+Wolfe&hometown=Austin,+TX", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<polyline")
+
+	w = get(t, a.ResultsChart, "/results/chart.svg?name=Nobody&hometown=Nowhere", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// the results page inlines the same chart
+	w = get(t, a.Results, "/results?name=Chris+Wolfe&hometown=Austin,+TX", "")
+	assert.Contains(t, w.Body.String(), "<svg")
+}
+
+func TestLifterPRsJSON(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.LifterPRsJSON, "/api/v1/lifters/prs?name=Chris+Wolfe&hometown=Austin,+TX", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var found db.PRHistory
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, "Chris Wolfe", found.Lifter)
+	assert.Len(t, found.PRs, 8)
+
+	w = get(t, a.LifterPRsJSON, "/api/v1/lifters/prs?name=Nobody&hometown=Nowhere", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
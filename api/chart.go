@@ -0,0 +1,55 @@
+package api
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"gitlab.com/derwolfe/faststats/chart"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+// lifterParams returns the single name and hometown query parameters that
+// identify a lifter, or a message describing what is wrong.
+func lifterParams(r *http.Request) (string, string, string) {
+	names, ok := r.URL.Query()["name"]
+	if !ok || len(names) != 1 || names[0] == "" {
+		return "", "", "Missing/too many name parameter!"
+	}
+	hometowns, ok := r.URL.Query()["hometown"]
+	if !ok || len(hometowns) != 1 {
+		return "", "", "Missing/too many hometown parameter!"
+	}
+	return names[0], hometowns[0], ""
+}
+
+// progressionChart is used by the results page to inline the chart.
+func progressionChart(rs *db.ResultsSummary) template.HTML {
+	return template.HTML(chart.Progression(rs).SVG())
+}
+
+// ResultsChart renders a lifter's progression as a standalone SVG.
+func (a API) ResultsChart(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		name, hometown, msg := lifterParams(r)
+		if msg != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Bad Request - " + msg))
+			return
+		}
+		found, err := a.db.QueryResults(name, hometown)
+		if err != nil {
+			log.Printf("error fetching results for chart: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Uh oh"))
+			return
+		}
+		if len(found.Results) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("404 - No results found for lifter"))
+			return
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write(chart.Progression(found).SVG())
+	}
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+
+	"gitlab.com/derwolfe/faststats/chart"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+// maxCompared keeps comparisons readable and bounds the queries per request.
+const maxCompared = 6
+
+// compareParams pairs up repeated name and hometown parameters, e.g.
+// ?name=A&hometown=X&name=B&hometown=Y.
+func compareParams(r *http.Request) ([]db.Lifter, string) {
+	q := r.URL.Query()
+	names, hometowns := q["name"], q["hometown"]
+	if len(names) != len(hometowns) {
+		return nil, "every name needs a matching hometown"
+	}
+	if len(names) < 2 || len(names) > maxCompared {
+		return nil, fmt.Sprintf("compare between 2 and %d lifters", maxCompared)
+	}
+	lifters := make([]db.Lifter, len(names))
+	for i := range names {
+		if names[i] == "" {
+			return nil, "names can't be empty"
+		}
+		lifters[i] = db.Lifter{Name: names[i], Hometown: hometowns[i]}
+		for _, l := range lifters[:i] {
+			if l.Name == names[i] && l.Hometown == hometowns[i] {
+				return nil, fmt.Sprintf("%v from %v is listed more than once", l.Name, l.Hometown)
+			}
+		}
+	}
+	return lifters, ""
+}
+
+// compare loads every lifter. The returned status is non-zero when the
+// comparison failed, which includes two of lifters being merged into the
+// same athlete.
+func (a API) compare(lifters []db.Lifter) (*db.Comparison, int, string) {
+	summaries := make([]*db.ResultsSummary, 0, len(lifters))
+	for _, l := range lifters {
+		rs, err := a.db.QueryResults(l.Name, l.Hometown)
+		if err != nil {
+			log.Printf("error fetching results for compare: %v\n", err)
+			return nil, http.StatusInternalServerError, "failed to fetch results"
+		}
+		if len(rs.Results) == 0 {
+			return nil, http.StatusNotFound, fmt.Sprintf("no results found for %v from %v", l.Name, l.Hometown)
+		}
+		for j, prev := range summaries {
+			if prev.Lifter == rs.Lifter && prev.Hometown == rs.Hometown {
+				return nil, http.StatusBadRequest, fmt.Sprintf("%v from %v and %v from %v are the same lifter", lifters[j].Name, lifters[j].Hometown, l.Name, l.Hometown)
+			}
+		}
+		summaries = append(summaries, rs)
+	}
+	return db.Compare(summaries), 0, ""
+}
+
+// comparisonCharts are the comparison page's charts, one per metric.
+func comparisonCharts(c *db.Comparison) []*chart.Chart {
+	return chart.Comparison(c.Summaries)
+}
+
+// svg inlines a chart in a page.
+func svg(c *chart.Chart) template.HTML {
+	return template.HTML(c.SVG())
+}
+
+// Compare shows several lifters side by side.
+func (a API) Compare(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		a.CompareJSON(w, r)
+		return
+	}
+	if r.Method == "GET" {
+		lifters, msg := compareParams(r)
+		if msg != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Bad Request - " + msg))
+			return
+		}
+		found, status, msg := a.compare(lifters)
+		if status != 0 {
+			w.WriteHeader(status)
+			w.Write([]byte(fmt.Sprintf("%d - %v", status, msg)))
+			return
+		}
+		a.render(w, a.comparePage, found)
+	}
+}
+
+// CompareJSON is the JSON version of Compare.
+func (a API) CompareJSON(w http.ResponseWriter, r *http.Request) {
+	if !jsonPreamble(w, r) {
+		return
+	}
+	lifters, msg := compareParams(r)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	found, status, msg := a.compare(lifters)
+	if status != 0 {
+		writeJSONError(w, status, msg)
+		return
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+var comparePage = `{{ define "content" }}
+<article class="uk-article">
+	<h1 class="uk-article-title">Head to head</h1>
+	<div class="uk-overflow-auto">
+		<table class="uk-table uk-table-divider uk-table-small">
+			<thead>
+				<tr>
+					<th class="uk-table-expand">Lifter</th>
+					<th>Meets</th>
+					<th>Active</th>
+					<th>Best SN</th>
+					<th>Best CJ</th>
+					<th>Best Total</th>
+					<th>Best Sinclair</th>
+					<th>SN makes</th>
+					<th>CJ makes</th>
+					<th>Recent weight</th>
+				</tr>
+			</thead>
+			<tbody>
+			{{ range .Lifters }}
+				<tr>
+					<td data-label="Lifter"><a href="results?name={{ .Lifter }}&hometown={{ .Hometown }}">{{ .Lifter }}</a> <a class="uk-text-muted" href="hometown?name={{ .Hometown }}">{{ .Hometown }}</a></td>
+					<td data-label="Meets">{{ .Meets }}</td>
+					<td data-label="Active">{{ .FirstMeet }} - {{ .LastMeet }}</td>
+					<td data-label="Best SN">{{ .BestSN }}</td>
+					<td data-label="Best CJ">{{ .BestCJ }}</td>
+					<td data-label="Best Total">{{ .BestTotal }}</td>
+					<td data-label="Best Sinclair">{{ .BestSinclair }}</td>
+					<td data-label="SN makes">{{ .AvgSNMakes }}%</td>
+					<td data-label="CJ makes">{{ .AvgCJMakes }}%</td>
+					<td data-label="Recent weight">{{ .RecentWeight }}</td>
+				</tr>
+			{{ end }}
+			</tbody>
+		</table>
+	</div>
+
+	<h3>Progression</h3>
+	<p class="uk-text-muted">Hover over a point for the meet.</p>
+	{{ range comparisonCharts . }}
+	<h4>{{ .Title }}</h4>
+	<div class="uk-margin">
+		{{ svg . }}
+	</div>
+	{{ end }}
+
+	<h3>Shared meets</h3>
+	{{ if not .SharedMeets }}
+		<p>These lifters haven't competed in the same meet.</p>
+	{{ end }}
+	{{ range .SharedMeets }}
+	<h4><a href="meet?name={{ .MeetName }}&date={{ .Date }}">{{ .MeetName }}</a> <span class="uk-text-muted">{{ .Date }}</span></h4>
+	<div class="uk-overflow-auto">
+		<table class="uk-table uk-table-divider uk-table-small">
+			<thead>
+				<tr>
+					<th>Place</th>
+					<th class="uk-table-expand">Lifter</th>
+					<th class="uk-text-nowrap">Class@weight</th>
+					<th>Best SN</th>
+					<th>Best CJ</th>
+					<th>Total</th>
+				</tr>
+			</thead>
+			<tbody>
+			{{ range .Entries }}
+				<tr>
+					<td data-label="Place">{{ if .Place }}{{ .Place }}{{ else }}-{{ end }}</td>
+					<td data-label="Lifter">{{ .Lifter }}</td>
+					<td data-label="Weight Class">{{ .Weightclass }} @ {{ .CompetitionWeight }}</td>
+					<td data-label="Best SN">{{ .BestSN }}</td>
+					<td data-label="Best CJ">{{ .BestCJ }}</td>
+					<td data-label="Total">{{ .Total }}</td>
+				</tr>
+			{{ end }}
+			</tbody>
+		</table>
+	</div>
+	{{ end }}
+</article>
+{{ end }}`
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+const compareQuery = "name=Chris+Wolfe&hometown=Austin,+TX&name=D%27Angelo+Osorio&hometown=Vallejo,+CA"
+
+func TestCompareHTML(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.Compare, "/compare?"+compareQuery, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Head to head")
+	assert.Contains(t, w.Body.String(), "<svg")
+
+	w = get(t, a.Compare, "/compare?name=Chris+Wolfe&hometown=Austin,+TX", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code, "a single lifter isn't a comparison")
+
+	w = get(t, a.Compare, "/compare?name=Chris+Wolfe&hometown=Austin,+TX&name=Nobody&hometown=Nowhere", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCompareJSON(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.CompareJSON, "/api/v1/compare?"+compareQuery, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var found db.Comparison
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Len(t, found.Lifters, 2)
+	assert.Len(t, found.SharedMeets, 2)
+}
+
+func TestCompareSameLifter(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.Compare, "/compare?name=Chris+Wolfe&hometown=Austin,+TX&name=Chris+Wolfe&hometown=Austin,+TX", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = get(t, a.CompareJSON, "/api/v1/compare?name=Chris+Wolfe&hometown=Austin,+TX&name=Chris+Wolfe&hometown=Austin,+TX", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// an alias is the same lifter too
+	store, err := db.LoadFixtures("../db/testdata/fixtures.jsonl")
+	assert.Nil(t, err)
+	defer store.Close()
+	assert.Nil(t, store.MergeLifters(db.Lifter{Name: "Kyle Brown", Hometown: "Reno, NV"}, db.Lifter{Name: "Kyle Brown", Hometown: "Portland, OR"}))
+	a = NewAPI(store)
+	w = get(t, a.CompareJSON, "/api/v1/compare?name=Kyle+Brown&hometown=Reno,+NV&name=Kyle+Brown&hometown=Portland,+OR", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "the same lifter")
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+// exportFilename turns a lifter and hometown into a safe download name such
+// as chris-wolfe-austin-tx.csv.
+func exportFilename(lifter, hometown, ext string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(lifter + " " + hometown) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if r != '\'' && !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	name := strings.TrimSuffix(b.String(), "-")
+	if name == "" {
+		name = "results"
+	}
+	return name + "." + ext
+}
+
+func setAttachment(w http.ResponseWriter, filename string) {
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+}
+
+var exportHeader = []string{
+	"date", "meet_name", "lifter", "hometown", "weight_class", "competition_weight",
+	"sn1", "sn2", "sn3", "best_sn", "sns_made",
+	"cj1", "cj2", "cj3", "best_cj", "cjs_made",
+	"total", "sinclair", "best_result", "url",
+}
+
+// writeResultsCSV writes one row per meet followed by a blank line and the
+// summary statistics as name,value pairs.
+func writeResultsCSV(out io.Writer, rs *db.ResultsSummary) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(exportHeader); err != nil {
+		return err
+	}
+	for _, r := range rs.Results {
+		err := w.Write([]string{
+			r.Date, r.MeetName, r.Lifter, r.Hometown, r.Weightclass, r.CompetitionWeight.String(),
+			r.SN1.String(), r.SN2.String(), r.SN3.String(), r.BestSN.String(), r.SNSMade.String(),
+			r.CJ1.String(), r.CJ2.String(), r.CJ3.String(), r.BestCJ.String(), r.CJSMade.String(),
+			r.Total.String(), r.Sinclair.String(), strconv.FormatBool(r.BestResult), r.URL,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	summary := [][]string{
+		{},
+		{"statistic", "value"},
+		{"lifter", rs.Lifter},
+		{"hometown", rs.Hometown},
+		{"meets", strconv.Itoa(len(rs.Results))},
+		{"best_sn", rs.BestSN.String()},
+		{"best_cj", rs.BestCJ.String()},
+		{"best_total", rs.BestTotal.String()},
+		{"best_sinclair", rs.BestSinclair.String()},
+		{"avg_sn_makes", rs.AvgSNMakes.String()},
+		{"avg_cj_makes", rs.AvgCJMakes.String()},
+		{"recent_weight", rs.RecentWeight.String()},
+	}
+	for _, row := range summary {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// exportResults loads the lifter named by the query for an export handler,
+// writing an error response and returning nil if it can't.
+func (a API) exportResults(w http.ResponseWriter, r *http.Request) *db.ResultsSummary {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("405 - Method Not Allowed"))
+		return nil
+	}
+	name, hometown, msg := lifterParams(r)
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Bad Request - " + msg))
+		return nil
+	}
+	found, err := a.db.QueryResults(name, hometown)
+	if err != nil {
+		log.Printf("error fetching results for export: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Uh oh"))
+		return nil
+	}
+	if len(found.Results) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - No results found for lifter"))
+		return nil
+	}
+	return found
+}
+
+// ResultsCSV downloads a lifter's competition history as CSV.
+func (a API) ResultsCSV(w http.ResponseWriter, r *http.Request) {
+	found := a.exportResults(w, r)
+	if found == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	setAttachment(w, exportFilename(found.Lifter, found.Hometown, "csv"))
+	if err := writeResultsCSV(w, found); err != nil {
+		log.Printf("error writing csv export: %v\n", err)
+	}
+}
+
+// ResultsExportJSON downloads a lifter's competition history as JSON.
+func (a API) ResultsExportJSON(w http.ResponseWriter, r *http.Request) {
+	found := a.exportResults(w, r)
+	if found == nil {
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJSON+"; charset=utf-8")
+	setAttachment(w, exportFilename(found.Lifter, found.Hometown, "json"))
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(found); err != nil {
+		log.Printf("error writing json export: %v\n", err)
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+func TestExportFilename(t *testing.T) {
+	assert.Equal(t, "chris-wolfe-austin-tx.csv", exportFilename("Chris Wolfe", "Austin, TX", "csv"))
+	assert.Equal(t, "dangelo-osorio-vallejo-ca.json", exportFilename("D'Angelo Osorio", "Vallejo, CA", "json"))
+	assert.Equal(t, "results.csv", exportFilename("", "", "csv"))
+}
+
+func TestResultsCSV(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.ResultsCSV, "/results.csv?name=Chris+Wolfe&hometown=Austin,+TX", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=chris-wolfe-austin-tx.csv`, w.Header().Get("Content-Disposition"))
+
+	r := csv.NewReader(strings.NewReader(w.Body.String()))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, exportHeader, rows[0])
+	// 3 meets, then the summary
+	assert.Equal(t, "2018-06-20", rows[1][0])
+	assert.Equal(t, []string{"statistic", "value"}, rows[4])
+	assert.Contains(t, rows, []string{"best_total", "192"})
+
+	w = get(t, a.ResultsCSV, "/results.csv?name=Nobody&hometown=Nowhere", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = get(t, a.ResultsCSV, "/results.csv?name=Chris+Wolfe", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestResultsExportJSON(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.ResultsExportJSON, "/results.json?name=Chris+Wolfe&hometown=Austin,+TX", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename=chris-wolfe-austin-tx.json`, w.Header().Get("Content-Disposition"))
+
+	var found db.ResultsSummary
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Len(t, found.Results, 3)
+	assert.Equal(t, "2", found.Results[0].CJSMade.String())
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"log"
+	"net/http"
+)
+
+func parseHometownsQuery(r *http.Request) (string, string, string) {
+	q := r.URL.Query()
+	if !validPage(q.Get("page")) {
+		return "", "", "page must be a positive integer"
+	}
+	return q.Get("name"), q.Get("page"), ""
+}
+
+// percentOf scales n against peak for bar widths.
+func percentOf(n, peak int64) int64 {
+	if peak == 0 {
+		return 0
+	}
+	return n * 100 / peak
+}
+
+// Hometowns lists hometowns by how many lifters have competed from them.
+func (a API) Hometowns(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		a.HometownsJSON(w, r)
+		return
+	}
+	if r.Method == "GET" {
+		name, page, msg := parseHometownsQuery(r)
+		if msg != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Bad Request - " + msg))
+			return
+		}
+		found, err := a.db.QueryHometowns(name, page)
+		if err != nil {
+			log.Printf("error fetching hometowns: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Uh oh"))
+			return
+		}
+		a.render(w, a.hometownsPage, found)
+	}
+}
+
+// Hometown shows every lifter who has competed from a hometown.
+func (a API) Hometown(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		a.HometownJSON(w, r)
+		return
+	}
+	if r.Method == "GET" {
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Bad Request - name is required!"))
+			return
+		}
+		found, err := a.db.QueryHometown(name)
+		if err != nil {
+			log.Printf("error fetching hometown: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Uh oh"))
+			return
+		}
+		a.render(w, a.hometownPage, found)
+	}
+}
+
+// HometownsJSON is the JSON version of Hometowns.
+func (a API) HometownsJSON(w http.ResponseWriter, r *http.Request) {
+	if !jsonPreamble(w, r) {
+		return
+	}
+	name, page, msg := parseHometownsQuery(r)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	found, err := a.db.QueryHometowns(name, page)
+	if err != nil {
+		log.Printf("error fetching hometowns: %v\n", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch hometowns")
+		return
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+// HometownJSON is the JSON version of Hometown.
+func (a API) HometownJSON(w http.ResponseWriter, r *http.Request) {
+	if !jsonPreamble(w, r) {
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeJSONError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	found, err := a.db.QueryHometown(name)
+	if err != nil {
+		log.Printf("error fetching hometown: %v\n", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch hometown")
+		return
+	}
+	if len(found.Lifters) == 0 {
+		writeJSONError(w, http.StatusNotFound, "hometown not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+var hometownsPage = `{{ define "content" }}
+<div class="uk-margin" uk-margin>
+	<form class="uk-form" action="/hometowns" method="GET" uk-form>
+		<input class="uk-input uk-form-width-medium" name="name" type="search" placeholder="Hometown or club" value="{{ .Name }}">
+		<button class="uk-button uk-button-default" type="submit" value="Search">Find hometowns</button>
+	</form>
+</div>
+
+<div class="uk-card">
+	{{ if eq .Total 0 }}
+		<p>No hometowns found</p>
+	{{ else }}
+		<p>Found {{ .Total }} hometowns</p>
+		<table class="uk-table uk-table-divider uk-table-hover">
+			<thead>
+				<tr>
+					<th>Hometown</th>
+					<th>Lifters</th>
+				</tr>
+			</thead>
+			<tbody>
+			{{ range .Hometowns }}
+				<tr>
+					<td data-label="Hometown"><a href="hometown?name={{ .Name }}">{{ .Name }}</a></td>
+					<td data-label="Lifters">{{ .Lifters }}</td>
+				</tr>
+			{{ end }}
+			</tbody>
+		</table>
+
+		{{ if (ne .TotalPages 1)}}
+		<div>
+			<ul class="uk-pagination uk-margin">
+			{{ range .Pages }}
+				{{ if .Gap }}
+					<li class="uk-disabled"><span>&hellip;</span></li>
+				{{ end }}
+				{{ if (eq .Display $.Current)}}
+					<li class="uk-active">
+				{{ else }}
+					<li>
+				{{ end }}
+					<a href="hometowns?name={{ $.Name }}&page={{ .Display }}">{{ .Display }}</a>
+				</li>
+			{{ end }}
+			</ul>
+		</div>
+		{{ end }}
+	{{ end }}
+</div>{{ end }}`
+
+var hometownPage = `{{ define "content" }}
+{{ if not .Lifters }}
+	No lifters found from {{ .Name }}
+{{ else }}
+<article class="uk-article">
+	<h1 class="uk-article-title">{{ .Name }}</h1>
+	<p class="uk-text-meta">{{ len .Lifters }} lifters - <a href="/hometowns">all hometowns</a></p>
+
+	<h3>Meet attendance</h3>
+	<div class="uk-overflow-auto">
+		<table class="uk-table uk-table-divider uk-table-small">
+			<thead>
+				<tr>
+					<th>Year</th>
+					<th>Meets</th>
+					<th>Lifters</th>
+					<th>Entries</th>
+					<th class="uk-width-1-2"></th>
+				</tr>
+			</thead>
+			<tbody>
+			{{ range .Attendance }}
+				<tr>
+					<td data-label="Year">{{ .Year }}</td>
+					<td data-label="Meets">{{ .Meets }}</td>
+					<td data-label="Lifters">{{ .Lifters }}</td>
+					<td data-label="Entries">{{ .Entries }}</td>
+					<td><progress class="uk-progress" value="{{ percentOf .Entries $.PeakEntries }}" max="100"></progress></td>
+				</tr>
+			{{ end }}
+			</tbody>
+		</table>
+	</div>
+
+	<h3>Lifters</h3>
+	<div class="uk-overflow-auto">
+		<table class="uk-table uk-table-divider uk-table-hover uk-table-small">
+			<thead>
+				<tr>
+					<th class="uk-table-expand">Lifter</th>
+					<th>Best Total</th>
+					<th>Meets</th>
+					<th>Active</th>
+				</tr>
+			</thead>
+			<tbody>
+			{{ range .Lifters }}
+				<tr>
+					<td data-label="Lifter"><a href="results?name={{ .Name }}&hometown={{ $.Name }}">{{ .Name }}</a></td>
+					<td data-label="Best Total">{{ .BestTotal }}</td>
+					<td data-label="Meets">{{ .Meets }}</td>
+					<td data-label="Active">{{ .FirstYear }}{{ if ne .FirstYear .LastYear }} - {{ .LastYear }}{{ end }}</td>
+				</tr>
+			{{ end }}
+			</tbody>
+		</table>
+	</div>
+</article>
+{{ end }}
+{{ end }}`
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+const (
+	contentTypeJSON = "application/json"
+	contentTypeHTML = "text/html"
+)
+
+// ErrorResponse is the body returned by the JSON API whenever a request fails.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes a failed JSON API request.
+type ErrorDetail struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", contentTypeJSON+"; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to encode json response: %v\n", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, ErrorResponse{Error: ErrorDetail{Status: status, Message: message}})
+}
+
+// acceptQuality returns the q value the Accept header gives to contentType.
+// A missing header accepts everything.
+func acceptQuality(accept, contentType string) float64 {
+	if strings.TrimSpace(accept) == "" {
+		return 1
+	}
+	major := strings.SplitN(contentType, "/", 2)[0]
+	best := 0.0
+	specificity := -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		// prefer the most specific match, e.g. application/json;q=0 beats */*
+		var s int
+		switch {
+		case mediaType == contentType:
+			s = 2
+		case mediaType == major+"/*":
+			s = 1
+		case mediaType == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(qs, 64); err == nil {
+				q = parsed
+			}
+		}
+		if s > specificity || (s == specificity && q > best) {
+			best = q
+			specificity = s
+		}
+	}
+	return best
+}
+
+// wantsJSON reports whether the client prefers JSON over HTML.
+func wantsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return false
+	}
+	return acceptQuality(accept, contentTypeJSON) > acceptQuality(accept, contentTypeHTML)
+}
+
+// acceptsJSON reports whether the client will take a JSON response at all.
+func acceptsJSON(r *http.Request) bool {
+	return acceptQuality(r.Header.Get("Accept"), contentTypeJSON) > 0
+}
+
+// jsonPreamble rejects requests the JSON API can't serve. It returns false
+// when an error response has already been written.
+func jsonPreamble(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	if !acceptsJSON(r) {
+		writeJSONError(w, http.StatusNotAcceptable, "this endpoint only serves application/json")
+		return false
+	}
+	return true
+}
+
+// LiftersJSON returns lifters matching the name query parameter as JSON.
+// Pages are selected by number with page, or with cursor set to the
+// next_cursor of the previous page.
+func (a API) LiftersJSON(w http.ResponseWriter, r *http.Request) {
+	if !jsonPreamble(w, r) {
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if len(name) < 3 {
+		writeJSONError(w, http.StatusBadRequest, "name must be at least 3 characters")
+		return
+	}
+	page := r.URL.Query().Get("page")
+	if page != "" {
+		p, err := strconv.ParseInt(page, 10, 64)
+		if err != nil || p < 1 {
+			writeJSONError(w, http.StatusBadRequest, "page must be a positive integer")
+			return
+		}
+	}
+	cursor := r.URL.Query().Get("cursor")
+	if cursor != "" && page != "" {
+		writeJSONError(w, http.StatusBadRequest, "use either page or cursor, not both")
+		return
+	}
+
+	var found *db.LiftersResponse
+	var err error
+	if cursor != "" {
+		found, err = a.db.QueryNamesAfter(name, cursor)
+	} else {
+		found, err = a.db.QueryNames(name, page)
+	}
+	if err == db.ErrInvalidCursor {
+		writeJSONError(w, http.StatusBadRequest, "cursor must be a next_cursor from an earlier page")
+		return
+	}
+	if err != nil {
+		log.Printf("error fetching names: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch lifters")
+		return
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+// LifterResultsJSON returns the results summary for a single lifter as JSON.
+func (a API) LifterResultsJSON(w http.ResponseWriter, r *http.Request) {
+	if !jsonPreamble(w, r) {
+		return
+	}
+	names, ok := r.URL.Query()["name"]
+	if !ok || len(names) != 1 || names[0] == "" {
+		writeJSONError(w, http.StatusBadRequest, "exactly one name parameter is required")
+		return
+	}
+	hometowns, ok := r.URL.Query()["hometown"]
+	if !ok || len(hometowns) != 1 {
+		writeJSONError(w, http.StatusBadRequest, "exactly one hometown parameter is required")
+		return
+	}
+
+	found, err := a.db.QueryResults(names[0], hometowns[0])
+	if err != nil {
+		log.Printf("error fetching results for name: %v\n", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch results")
+		return
+	}
+	if len(found.Results) == 0 {
+		writeJSONError(w, http.StatusNotFound, "no results found for lifter")
+		return
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+// LifterPRsJSON returns every personal record a lifter has set, oldest first.
+func (a API) LifterPRsJSON(w http.ResponseWriter, r *http.Request) {
+	if !jsonPreamble(w, r) {
+		return
+	}
+	name, hometown, msg := lifterParams(r)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	found, err := a.db.QueryResults(name, hometown)
+	if err != nil {
+		log.Printf("error fetching results for name: %v\n", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch results")
+		return
+	}
+	if len(found.Results) == 0 {
+		writeJSONError(w, http.StatusNotFound, "no results found for lifter")
+		return
+	}
+	writeJSON(w, http.StatusOK, db.PRHistory{Lifter: found.Lifter, Hometown: found.Hometown, PRs: found.PRHistory})
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWantsJSON(t *testing.T) {
+	cases := []struct {
+		accept   string
+		expected bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"application/json", true},
+		{"application/json, text/html;q=0.5", true},
+		{"text/html, application/json;q=0.5", false},
+		{"application/*", true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/search?name=mos", nil)
+			r.Header.Set("Accept", tt.accept)
+			assert.Equal(t, tt.expected, wantsJSON(r))
+		})
+	}
+}
+
+func TestAcceptsJSON(t *testing.T) {
+	cases := []struct {
+		accept   string
+		expected bool
+	}{
+		{"", true},
+		{"*/*", true},
+		{"text/html", false},
+		{"*/*, application/json;q=0", false},
+		{"text/html, application/json;q=0.1", true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/lifters?name=mos", nil)
+			r.Header.Set("Accept", tt.accept)
+			assert.Equal(t, tt.expected, acceptsJSON(r))
+		})
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	a := API{}
+	cases := []struct {
+		name    string
+		method  string
+		url     string
+		accept  string
+		handler http.HandlerFunc
+		status  int
+	}{
+		{"short name", "GET", "/api/v1/lifters?name=mo", "", a.LiftersJSON, http.StatusBadRequest},
+		{"bad page", "GET", "/api/v1/lifters?name=mos&page=0", "", a.LiftersJSON, http.StatusBadRequest},
+		{"not a page", "GET", "/api/v1/lifters?name=mos&page=one", "", a.LiftersJSON, http.StatusBadRequest},
+		{"wrong method", "POST", "/api/v1/lifters?name=mos", "", a.LiftersJSON, http.StatusMethodNotAllowed},
+		{"html only", "GET", "/api/v1/lifters?name=mos", "text/html", a.LiftersJSON, http.StatusNotAcceptable},
+		{"missing hometown", "GET", "/api/v1/lifters/results?name=chris", "", a.LifterResultsJSON, http.StatusBadRequest},
+		{"missing name", "GET", "/api/v1/lifters/results?hometown=x", "", a.LifterResultsJSON, http.StatusBadRequest},
+		{"negotiated search", "GET", "/search?name=mo", "application/json", a.Search, http.StatusBadRequest},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.url, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			tt.handler(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+			var body ErrorResponse
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body), "error body was not json")
+			assert.Equal(t, tt.status, body.Error.Status)
+			assert.NotEmpty(t, body.Error.Message)
+		})
+	}
+}
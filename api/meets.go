@@ -0,0 +1,235 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+// validDate reports whether s is empty or a YYYY-MM-DD date.
+func validDate(s string) bool {
+	if s == "" {
+		return true
+	}
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// validPage reports whether s is empty or a positive page number.
+func validPage(s string) bool {
+	if s == "" {
+		return true
+	}
+	p, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && p >= 1
+}
+
+func parseMeetFilter(r *http.Request) (db.MeetFilter, string) {
+	q := r.URL.Query()
+	f := db.MeetFilter{Name: q.Get("name"), From: q.Get("from"), To: q.Get("to")}
+	if !validDate(f.From) || !validDate(f.To) {
+		return f, "from and to must be dates formatted as YYYY-MM-DD"
+	}
+	if !validPage(q.Get("page")) {
+		return f, "page must be a positive integer"
+	}
+	return f, ""
+}
+
+// Meets lists meets, optionally filtered by name and date range.
+func (a API) Meets(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		a.MeetsJSON(w, r)
+		return
+	}
+	if r.Method == "GET" {
+		f, msg := parseMeetFilter(r)
+		if msg != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Bad Request - " + msg))
+			return
+		}
+		found, err := a.db.QueryMeets(f, r.URL.Query().Get("page"))
+		if err != nil {
+			log.Printf("error fetching meets: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Uh oh"))
+			return
+		}
+		a.render(w, a.meetsPage, found)
+	}
+}
+
+// Meet shows every entry in a single meet.
+func (a API) Meet(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		a.MeetJSON(w, r)
+		return
+	}
+	if r.Method == "GET" {
+		name, date := r.URL.Query().Get("name"), r.URL.Query().Get("date")
+		if name == "" || date == "" || !validDate(date) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Bad Request - name and date (YYYY-MM-DD) are required!"))
+			return
+		}
+		found, err := a.db.QueryMeet(name, date)
+		if err != nil {
+			log.Printf("error fetching meet: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Uh oh"))
+			return
+		}
+		a.render(w, a.meetPage, found)
+	}
+}
+
+// MeetsJSON is the JSON version of Meets.
+func (a API) MeetsJSON(w http.ResponseWriter, r *http.Request) {
+	if !jsonPreamble(w, r) {
+		return
+	}
+	f, msg := parseMeetFilter(r)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	found, err := a.db.QueryMeets(f, r.URL.Query().Get("page"))
+	if err != nil {
+		log.Printf("error fetching meets: %v\n", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch meets")
+		return
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+// MeetJSON is the JSON version of Meet.
+func (a API) MeetJSON(w http.ResponseWriter, r *http.Request) {
+	if !jsonPreamble(w, r) {
+		return
+	}
+	name, date := r.URL.Query().Get("name"), r.URL.Query().Get("date")
+	if name == "" || date == "" || !validDate(date) {
+		writeJSONError(w, http.StatusBadRequest, "name and date (YYYY-MM-DD) are required")
+		return
+	}
+	found, err := a.db.QueryMeet(name, date)
+	if err != nil {
+		log.Printf("error fetching meet: %v\n", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch meet")
+		return
+	}
+	if found.Entries == 0 {
+		writeJSONError(w, http.StatusNotFound, "meet not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+var meetsPage = `{{ define "content" }}
+<div class="uk-margin" uk-margin>
+	<form class="uk-form" action="/meets" method="GET" uk-form>
+		<input class="uk-input uk-form-width-medium" name="name" type="search" placeholder="Meet name" value="{{ .Filter.Name }}">
+		<input class="uk-input uk-form-width-small" name="from" type="date" value="{{ .Filter.From }}">
+		<input class="uk-input uk-form-width-small" name="to" type="date" value="{{ .Filter.To }}">
+		<button class="uk-button uk-button-default" type="submit" value="Search">Find meets</button>
+	</form>
+</div>
+
+<div class="uk-card">
+	{{ if eq .Total 0 }}
+		<p>No meets found</p>
+	{{ else }}
+		<p>Found {{ .Total }} meets</p>
+		<table class="uk-table uk-table-divider uk-table-hover">
+			<thead>
+				<tr>
+					<th>Meet Date</th>
+					<th>Meet</th>
+					<th>Entries</th>
+				</tr>
+			</thead>
+			<tbody>
+			{{ range .Meets }}
+				<tr>
+					<td data-label="Meet Date">{{ .Date }}</td>
+					<td data-label="Meet"><a href="meet?name={{ .Name }}&date={{ .Date }}">{{ .Name }}</a></td>
+					<td data-label="Entries">{{ .Entries }}</td>
+				</tr>
+			{{ end }}
+			</tbody>
+		</table>
+
+		{{ if (ne .TotalPages 1)}}
+		<div>
+			<ul class="uk-pagination uk-margin">
+			{{ range .Pages }}
+				{{ if .Gap }}
+					<li class="uk-disabled"><span>&hellip;</span></li>
+				{{ end }}
+				{{ if (eq .Display $.Current)}}
+					<li class="uk-active">
+				{{ else }}
+					<li>
+				{{ end }}
+					<a href="meets?name={{ $.Filter.Name }}&from={{ $.Filter.From }}&to={{ $.Filter.To }}&page={{ .Display }}">{{ .Display }}</a>
+				</li>
+			{{ end }}
+			</ul>
+		</div>
+		{{ end }}
+	{{ end }}
+</div>{{ end }}`
+
+var meetPage = `{{ define "content" }}
+{{ if eq .Entries 0 }}
+	No results found for {{ .Name }} on {{ .Date }}
+{{ else }}
+<article class="uk-article">
+	<h1 class="uk-article-title">{{ .Name }}</h1>
+	<p class="uk-text-meta">{{ .Date }} - {{ .Entries }} entries - <a rel="noopener noreferrer" target="_blank" href="{{ .URL }}&isPopup=&Tab=Results">USAW results</a></p>
+	{{ range .WeightClasses }}
+	<h3>{{ .Weightclass }}</h3>
+	<div class="uk-overflow-auto">
+		<table class="uk-table uk-table-divider uk-table-hover uk-table-small">
+			<thead>
+				<tr>
+					<th>Place</th>
+					<th class="uk-table-expand">Lifter</th>
+					<th>Bodyweight</th>
+					<th>SN1</th>
+					<th>SN2</th>
+					<th>SN3</th>
+					<th>CJ1</th>
+					<th>CJ2</th>
+					<th>CJ3</th>
+					<th>Total</th>
+					<th>Sinclair</th>
+				</tr>
+			</thead>
+			<tbody>
+			{{ range .Entries }}
+				<tr>
+					<td data-label="Place">{{ if .Place }}{{ .Place }}{{ else }}-{{ end }}</td>
+					<td data-label="Lifter"><a href="results?name={{ .Lifter }}&hometown={{ .Hometown }}">{{ .Lifter }}</a> <a class="uk-text-muted" href="hometown?name={{ .Hometown }}">{{ .Hometown }}</a></td>
+					<td data-label="Bodyweight">{{ .CompetitionWeight }}</td>
+					<td data-label="SN1">{{ .SN1 }}</td>
+					<td data-label="SN2">{{ .SN2 }}</td>
+					<td data-label="SN3">{{ .SN3 }}</td>
+					<td data-label="CJ1">{{ .CJ1 }}</td>
+					<td data-label="CJ2">{{ .CJ2 }}</td>
+					<td data-label="CJ3">{{ .CJ3 }}</td>
+					<td data-label="Total">{{ .Total }}</td>
+					<td data-label="Sinclair">{{ .Sinclair }}</td>
+				</tr>
+			{{ end }}
+			</tbody>
+		</table>
+	</div>
+	{{ end }}
+</article>
+{{ end }}
+{{ end }}`
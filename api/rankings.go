@@ -0,0 +1,181 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+func parseRankingFilter(r *http.Request) (db.RankingFilter, string) {
+	q := r.URL.Query()
+	f := db.RankingFilter{
+		From:        q.Get("from"),
+		To:          q.Get("to"),
+		Weightclass: q.Get("weight_class"),
+		ModernClass: q.Get("modern_class"),
+		Metric:      db.Metric(q.Get("metric")),
+	}
+	if f.Metric == "" {
+		f.Metric = db.MetricTotal
+	}
+	if !f.Metric.Valid() {
+		return f, "metric must be one of total, snatch, cleanjerk or sinclair"
+	}
+	if f.ModernClass != "" {
+		if _, err := db.ParseModernClass(f.ModernClass); err != nil {
+			return f, "modern_class must be a 2018 weight class such as Men's 81Kg"
+		}
+	}
+	year, err := db.ParseYear(q.Get("year"))
+	if err != nil {
+		return f, "year must be a four digit year"
+	}
+	f.Year = year
+	gender, err := db.ParseGender(q.Get("gender"))
+	if err != nil {
+		return f, "gender must be male or female"
+	}
+	f.Gender = gender
+	if !validDate(f.From) || !validDate(f.To) {
+		return f, "from and to must be dates formatted as YYYY-MM-DD"
+	}
+	if !validPage(q.Get("page")) {
+		return f, "page must be a positive integer"
+	}
+	return f, ""
+}
+
+// Rankings lists the best lifters matching the year, date range, weight
+// class, gender and metric query parameters.
+func (a API) Rankings(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		a.RankingsJSON(w, r)
+		return
+	}
+	if r.Method == "GET" {
+		f, msg := parseRankingFilter(r)
+		if msg != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Bad Request - " + msg))
+			return
+		}
+		found, err := a.db.QueryRankings(f, r.URL.Query().Get("page"))
+		if err != nil {
+			log.Printf("error fetching rankings: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Uh oh"))
+			return
+		}
+		a.render(w, a.rankingsPage, found)
+	}
+}
+
+// RankingsJSON is the JSON version of Rankings.
+func (a API) RankingsJSON(w http.ResponseWriter, r *http.Request) {
+	if !jsonPreamble(w, r) {
+		return
+	}
+	f, msg := parseRankingFilter(r)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	found, err := a.db.QueryRankings(f, r.URL.Query().Get("page"))
+	if err != nil {
+		log.Printf("error fetching rankings: %v\n", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch rankings")
+		return
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+var rankingsPage = `{{ define "content" }}
+<div class="uk-margin" uk-margin>
+	<form class="uk-form uk-grid-small" action="/rankings" method="GET" uk-grid>
+		<div class="uk-width-auto">
+			<select class="uk-select" name="gender">
+				<option value="" {{ if eq .Gender "" }}selected{{ end }}>All lifters</option>
+				<option value="female" {{ if eq .Gender "female" }}selected{{ end }}>Women</option>
+				<option value="male" {{ if eq .Gender "male" }}selected{{ end }}>Men</option>
+			</select>
+		</div>
+		<div class="uk-width-auto">
+			<input class="uk-input uk-form-width-small" name="year" type="number" placeholder="Year" value="{{ if .Filter.Year }}{{ .Filter.Year }}{{ end }}">
+		</div>
+		<div class="uk-width-auto">
+			<input class="uk-input uk-form-width-medium" name="weight_class" type="text" placeholder="Weight class" value="{{ .Filter.Weightclass }}">
+		</div>
+		<div class="uk-width-auto">
+			<input class="uk-input uk-form-width-medium" name="modern_class" type="text" placeholder="Modern class, e.g. Men's 81Kg" value="{{ .Filter.ModernClass }}">
+		</div>
+		<div class="uk-width-auto">
+			<select class="uk-select" name="metric">
+				<option value="total" {{ if eq .Filter.Metric "total" }}selected{{ end }}>Total</option>
+				<option value="snatch" {{ if eq .Filter.Metric "snatch" }}selected{{ end }}>Snatch</option>
+				<option value="cleanjerk" {{ if eq .Filter.Metric "cleanjerk" }}selected{{ end }}>Clean & Jerk</option>
+				<option value="sinclair" {{ if eq .Filter.Metric "sinclair" }}selected{{ end }}>Sinclair</option>
+			</select>
+		</div>
+		<div class="uk-width-auto">
+			<button class="uk-button uk-button-default" type="submit" value="Search">Rank</button>
+		</div>
+	</form>
+</div>
+
+<div class="uk-card">
+	{{ if eq .Total 0 }}
+		<p>No results found</p>
+	{{ else }}
+		<p>Ranked {{ .Total }} lifters by {{ .Filter.Metric }}</p>
+		<div class="uk-overflow-auto">
+			<table class="uk-table uk-table-divider uk-table-hover uk-table-small">
+				<thead>
+					<tr>
+						<th>Rank</th>
+						<th class="uk-table-expand">Lifter</th>
+						<th class="uk-text-nowrap">Class@weight</th>
+						<th>Snatch</th>
+						<th>CJ</th>
+						<th>Total</th>
+						<th>Sinclair</th>
+						<th class="uk-text-nowrap">Meet</th>
+					</tr>
+				</thead>
+				<tbody>
+				{{ range .Rankings }}
+					<tr>
+						<td data-label="Rank">{{ .Rank }}</td>
+						<td data-label="Lifter"><a href="results?name={{ .Lifter }}&hometown={{ .Hometown }}">{{ .Lifter }}</a> <a class="uk-text-muted" href="hometown?name={{ .Hometown }}">{{ .Hometown }}</a></td>
+						<td data-label="Weight Class">{{ .Weightclass }} @ {{ .CompetitionWeight }}</td>
+						<td data-label="Snatch">{{ .BestSN }}</td>
+						<td data-label="CJ">{{ .BestCJ }}</td>
+						<td data-label="Total">{{ .Total }}</td>
+						<td data-label="Sinclair">{{ .Sinclair }}</td>
+						<td data-label="Meet"><a href="meet?name={{ .MeetName }}&date={{ .Date }}">{{ .MeetName }}</a> {{ .Date }}</td>
+					</tr>
+				{{ end }}
+				</tbody>
+			</table>
+		</div>
+
+		{{ if (ne .TotalPages 1)}}
+		<div>
+			<ul class="uk-pagination uk-margin">
+			{{ range .Pages }}
+				{{ if .Gap }}
+					<li class="uk-disabled"><span>&hellip;</span></li>
+				{{ end }}
+				{{ if (eq .Display $.Current)}}
+					<li class="uk-active">
+				{{ else }}
+					<li>
+				{{ end }}
+					<a href="rankings?gender={{ $.Gender }}&year={{ if $.Filter.Year }}{{ $.Filter.Year }}{{ end }}&from={{ $.Filter.From }}&to={{ $.Filter.To }}&weight_class={{ $.Filter.Weightclass }}&modern_class={{ $.Filter.ModernClass }}&metric={{ $.Filter.Metric }}&page={{ .Display }}">{{ .Display }}</a>
+				</li>
+			{{ end }}
+			</ul>
+		</div>
+		{{ end }}
+	{{ end }}
+</div>{{ end }}`
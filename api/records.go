@@ -0,0 +1,167 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+func parseRecordFilter(r *http.Request) (db.RecordFilter, string) {
+	q := r.URL.Query()
+	f := db.RecordFilter{
+		Weightclass: q.Get("weight_class"),
+		ModernClass: q.Get("modern_class"),
+		Lift:        db.Lift(q.Get("lift")),
+	}
+	if f.Lift != "" && !f.Lift.Valid() {
+		return f, "lift must be one of snatch, cleanjerk or total"
+	}
+	if f.ModernClass != "" {
+		if _, err := db.ParseModernClass(f.ModernClass); err != nil {
+			return f, "modern_class must be a 2018 weight class such as Men's 81Kg"
+		}
+	}
+	gender, err := db.ParseGender(q.Get("gender"))
+	if err != nil {
+		return f, "gender must be male or female"
+	}
+	f.Gender = gender
+	return f, ""
+}
+
+// Records shows the standing record for every weight class and lift
+// matching the gender, weight class, modern class and lift query parameters.
+// The full progression is shown once a weight class is picked.
+func (a API) Records(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		a.RecordsJSON(w, r)
+		return
+	}
+	if r.Method == "GET" {
+		f, msg := parseRecordFilter(r)
+		if msg != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - Bad Request - " + msg))
+			return
+		}
+		found, err := a.db.QueryRecords(f)
+		if err != nil {
+			log.Printf("error fetching records: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Uh oh"))
+			return
+		}
+		a.render(w, a.recordsPage, found)
+	}
+}
+
+// RecordsJSON is the JSON version of Records, progressions are always
+// included.
+func (a API) RecordsJSON(w http.ResponseWriter, r *http.Request) {
+	if !jsonPreamble(w, r) {
+		return
+	}
+	f, msg := parseRecordFilter(r)
+	if msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	found, err := a.db.QueryRecords(f)
+	if err != nil {
+		log.Printf("error fetching records: %v\n", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch records")
+		return
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+var recordsPage = `{{ define "content" }}
+<div class="uk-margin" uk-margin>
+	<form class="uk-form uk-grid-small" action="/records" method="GET" uk-grid>
+		<div class="uk-width-auto">
+			<select class="uk-select" name="gender">
+				<option value="" {{ if eq .Gender "" }}selected{{ end }}>All lifters</option>
+				<option value="female" {{ if eq .Gender "female" }}selected{{ end }}>Women</option>
+				<option value="male" {{ if eq .Gender "male" }}selected{{ end }}>Men</option>
+			</select>
+		</div>
+		<div class="uk-width-auto">
+			<input class="uk-input uk-form-width-medium" name="weight_class" type="text" placeholder="Weight class" value="{{ .Filter.Weightclass }}">
+		</div>
+		<div class="uk-width-auto">
+			<input class="uk-input uk-form-width-medium" name="modern_class" type="text" placeholder="Modern class, e.g. Men's 81Kg" value="{{ .Filter.ModernClass }}">
+		</div>
+		<div class="uk-width-auto">
+			<select class="uk-select" name="lift">
+				<option value="" {{ if eq .Filter.Lift "" }}selected{{ end }}>All lifts</option>
+				<option value="snatch" {{ if eq .Filter.Lift "snatch" }}selected{{ end }}>Snatch</option>
+				<option value="cleanjerk" {{ if eq .Filter.Lift "cleanjerk" }}selected{{ end }}>Clean & Jerk</option>
+				<option value="total" {{ if eq .Filter.Lift "total" }}selected{{ end }}>Total</option>
+			</select>
+		</div>
+		<div class="uk-width-auto">
+			<button class="uk-button uk-button-default" type="submit" value="Search">Show</button>
+		</div>
+	</form>
+</div>
+
+<div class="uk-card">
+	<p class="uk-text-muted">The best lifts in this data, not official USAW records.</p>
+	{{ if not .Records }}
+		<p>No records found</p>
+	{{ else if not (or .Filter.Weightclass .Filter.ModernClass) }}
+		<div class="uk-overflow-auto">
+			<table class="uk-table uk-table-divider uk-table-hover uk-table-small">
+				<thead>
+					<tr>
+						<th class="uk-text-nowrap">Weight class</th>
+						<th>Lift</th>
+						<th>Record</th>
+						<th class="uk-table-expand">Held by</th>
+						<th class="uk-text-nowrap">Meet</th>
+					</tr>
+				</thead>
+				<tbody>
+				{{ range .Records }}
+					<tr>
+						<td data-label="Weight class"><a href="records?weight_class={{ .Weightclass }}&lift={{ .Lift }}">{{ .Weightclass }}</a></td>
+						<td data-label="Lift">{{ .Lift }}</td>
+						<td data-label="Record">{{ .Current.Value }}</td>
+						<td data-label="Held by"><a href="results?name={{ .Current.Lifter }}&hometown={{ .Current.Hometown }}">{{ .Current.Lifter }}</a> <a class="uk-text-muted" href="hometown?name={{ .Current.Hometown }}">{{ .Current.Hometown }}</a></td>
+						<td data-label="Meet"><a href="meet?name={{ .Current.MeetName }}&date={{ .Current.Date }}">{{ .Current.MeetName }}</a> {{ .Current.Date }}</td>
+					</tr>
+				{{ end }}
+				</tbody>
+			</table>
+		</div>
+	{{ else }}
+		{{ range .Records }}
+		<h3>{{ .Weightclass }} {{ .Lift }}: {{ .Current.Value }} kg</h3>
+		<div class="uk-overflow-auto">
+			<table class="uk-table uk-table-divider uk-table-hover uk-table-small">
+				<thead>
+					<tr>
+						<th>Date</th>
+						<th>Record</th>
+						<th>Broke</th>
+						<th class="uk-table-expand">Lifter</th>
+						<th class="uk-text-nowrap">Meet</th>
+					</tr>
+				</thead>
+				<tbody>
+				{{ range .History }}
+					<tr>
+						<td data-label="Date">{{ .Date }}</td>
+						<td data-label="Record">{{ .Value }}</td>
+						<td data-label="Broke">{{ if .Previous.IsZero }}-{{ else }}{{ .Previous }}{{ end }}</td>
+						<td data-label="Lifter"><a href="results?name={{ .Lifter }}&hometown={{ .Hometown }}">{{ .Lifter }}</a> <a class="uk-text-muted" href="hometown?name={{ .Hometown }}">{{ .Hometown }}</a></td>
+						<td data-label="Meet"><a href="meet?name={{ .MeetName }}&date={{ .Date }}">{{ .MeetName }}</a></td>
+					</tr>
+				{{ end }}
+				</tbody>
+			</table>
+		</div>
+		{{ end }}
+	{{ end }}
+</div>{{ end }}`
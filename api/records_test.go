@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+func TestRecordsHTML(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.Records, "/records?gender=male&lift=total", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "D&#39;Angelo Osorio")
+
+	w = get(t, a.Records, "/records?weight_class=Men%27s+77Kg&lift=total", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Texas State Championships", "a weight class shows the progression")
+
+	w = get(t, a.Records, "/records?lift=bench", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get(t, a.Records, "/records?modern_class=Men%27s+77Kg", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code, "77 kg isn't a modern class")
+}
+
+func TestRecordsJSON(t *testing.T) {
+	a := NewAPI(fixtures)
+	w := get(t, a.RecordsJSON, "/api/v1/records?weight_class=Men%27s+77Kg", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var found db.RecordsResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Len(t, found.Records, 3)
+	assert.Equal(t, db.LiftSnatch, found.Records[0].Lift)
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultSuggestions = 8
+	maxSuggestions     = 20
+)
+
+// SuggestJSON offers lifters whose names start with q as the search box is
+// typed in.
+func (a API) SuggestJSON(w http.ResponseWriter, r *http.Request) {
+	if !jsonPreamble(w, r) {
+		return
+	}
+	q := r.URL.Query().Get("q")
+	if len(q) > 100 {
+		writeJSONError(w, http.StatusBadRequest, "q must be at most 100 characters")
+		return
+	}
+	limit := defaultSuggestions
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxSuggestions {
+			writeJSONError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxSuggestions))
+			return
+		}
+		limit = n
+	}
+
+	found, err := a.db.Suggest(q, limit)
+	if err != nil {
+		log.Printf("error fetching suggestions: %v\n", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch suggestions")
+		return
+	}
+	// suggestions only change on import, let browsers reuse them for a bit
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, found)
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(2, 0)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	// a is now more recently used than b
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	c.Add("c", 3)
+	assert.Equal(t, 2, c.Len())
+	_, ok = c.Get("b")
+	assert.False(t, ok, "b was evicted")
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+}
+
+func TestLRUReplaces(t *testing.T) {
+	c := New(2, 0)
+	c.Add("a", 1)
+	c.Add("a", 2)
+	assert.Equal(t, 1, c.Len())
+	v, _ := c.Get("a")
+	assert.Equal(t, 2, v)
+}
+
+func TestLRUExpires(t *testing.T) {
+	now := time.Date(2018, 6, 20, 12, 0, 0, 0, time.UTC)
+	c := New(10, time.Minute)
+	c.now = func() time.Time { return now }
+	c.Add("a", 1)
+
+	now = now.Add(59 * time.Second)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len(), "expired values are dropped when looked up")
+}
+
+func TestLRUPurge(t *testing.T) {
+	c := New(10, 0)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestLRUZeroSizeCachesNothing(t *testing.T) {
+	c := New(0, 0)
+	c.Add("a", 1)
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
@@ -0,0 +1,268 @@
+// Package chart renders simple line charts as standalone SVG so pages don't
+// need any javascript to show them.
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+const (
+	width        = 800
+	height       = 360
+	marginLeft   = 50
+	marginRight  = 20
+	marginTop    = 20
+	marginBottom = 60
+	yTicks       = 5
+)
+
+// Point is a single value on a date.
+type Point struct {
+	Date  time.Time
+	Value float64
+	// Label is shown when hovering over the point
+	Label string
+}
+
+// Series is one line on a chart.
+type Series struct {
+	Name   string
+	Color  string
+	Points []Point
+}
+
+// Chart is a set of series sharing a date x axis and a y axis.
+type Chart struct {
+	Title  string
+	YLabel string
+	Series []Series
+	// From and To widen the x axis beyond the points, so charts drawn
+	// together can share a timeline
+	From, To time.Time
+}
+
+// Progression charts a lifter's best snatch, best clean & jerk, total and
+// bodyweight at every meet. Missed lifts and bomb outs are left off rather
+// than plotted as zero.
+func Progression(rs *db.ResultsSummary) *Chart {
+	c := &Chart{
+		Title:  fmt.Sprintf("%v / %v", rs.Lifter, rs.Hometown),
+		YLabel: "kg",
+		Series: []Series{
+			{Name: "Total", Color: "#1e87f0"},
+			{Name: "Best CJ", Color: "#32d296"},
+			{Name: "Best SN", Color: "#faa05a"},
+			{Name: "Bodyweight", Color: "#999999"},
+		},
+	}
+	for _, r := range rs.Results {
+		d, err := time.Parse("2006-01-02", r.Date)
+		if err != nil {
+			continue
+		}
+		for i, v := range []decimal.Decimal{r.Total, r.BestCJ, r.BestSN, r.CompetitionWeight} {
+			f, _ := v.Float64()
+			if f <= 0 {
+				continue
+			}
+			c.Series[i].Points = append(c.Series[i].Points, Point{
+				Date:  d,
+				Value: f,
+				Label: fmt.Sprintf("%v %v: %v kg (%v)", c.Series[i].Name, r.Date, f, r.MeetName),
+			})
+		}
+	}
+	// results come back most recent first
+	for i := range c.Series {
+		points := c.Series[i].Points
+		sort.Slice(points, func(i, j int) bool { return points[i].Date.Before(points[j].Date) })
+	}
+	return c
+}
+
+// niceMax rounds v up to a value that divides evenly into yTicks steps.
+func niceMax(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	step := v / yTicks
+	mag := math.Pow(10, math.Floor(math.Log10(step)))
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if m*mag >= step {
+			return m * mag * yTicks
+		}
+	}
+	return 10 * mag * yTicks
+}
+
+func (c *Chart) bounds() (time.Time, time.Time, float64, bool) {
+	var first, last time.Time
+	maxV := 0.0
+	found := false
+	for _, s := range c.Series {
+		for _, p := range s.Points {
+			if !found || p.Date.Before(first) {
+				first = p.Date
+			}
+			if !found || p.Date.After(last) {
+				last = p.Date
+			}
+			maxV = math.Max(maxV, p.Value)
+			found = true
+		}
+	}
+	if found && !c.From.IsZero() && c.From.Before(first) {
+		first = c.From
+	}
+	if found && c.To.After(last) {
+		last = c.To
+	}
+	return first, last, maxV, found
+}
+
+// SVG renders the chart as a complete svg document.
+func (c *Chart) SVG() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="100%%" role="img" aria-label="%s" font-family="sans-serif" font-size="12">`, width, height, html.EscapeString(c.Title))
+	fmt.Fprintf(&b, `<title>%s</title>`, html.EscapeString(c.Title))
+
+	first, last, maxV, ok := c.bounds()
+	if !ok {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">No results to chart</text></svg>`, width/2, height/2)
+		return b.Bytes()
+	}
+	// a single meet still needs some width to draw on
+	if !last.After(first) {
+		first = first.AddDate(0, -1, 0)
+		last = last.AddDate(0, 1, 0)
+	}
+	top := niceMax(maxV)
+	plotW := float64(width - marginLeft - marginRight)
+	plotH := float64(height - marginTop - marginBottom)
+	x := func(t time.Time) float64 {
+		return marginLeft + plotW*float64(t.Sub(first))/float64(last.Sub(first))
+	}
+	y := func(v float64) float64 {
+		return marginTop + plotH*(1-v/top)
+	}
+
+	// y axis grid and labels
+	for i := 0; i <= yTicks; i++ {
+		v := top * float64(i) / yTicks
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e5e5e5"/>`, marginLeft, y(v), width-marginRight, y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle" fill="#666">%g</text>`, marginLeft-6, y(v), v)
+	}
+	fmt.Fprintf(&b, `<text x="12" y="%d" fill="#666" transform="rotate(-90 12 %d)" text-anchor="middle">%s</text>`, marginTop+int(plotH/2), marginTop+int(plotH/2), html.EscapeString(c.YLabel))
+
+	// x axis with a label per year
+	fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#666"/>`, marginLeft, y(0), width-marginRight, y(0))
+	for year := first.Year() + 1; year <= last.Year(); year++ {
+		t := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#666"/>`, x(t), y(0), x(t), y(0)+5)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle" fill="#666">%d</text>`, x(t), y(0)+18, year)
+	}
+
+	for i, s := range c.Series {
+		if len(s.Points) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, `<g><polyline fill="none" stroke="%s" stroke-width="2" points="`, s.Color)
+		for j, p := range s.Points {
+			if j > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(&b, "%.1f,%.1f", x(p.Date), y(p.Value))
+		}
+		b.WriteString(`"/>`)
+		for _, p := range s.Points {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s</title></circle>`, x(p.Date), y(p.Value), s.Color, html.EscapeString(p.Label))
+		}
+		b.WriteString(`</g>`)
+
+		// legend along the bottom
+		lx := marginLeft + i*140
+		ly := height - 15
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/>`, lx, ly-10, s.Color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`, lx+16, ly, html.EscapeString(s.Name))
+	}
+	b.WriteString(`</svg>`)
+	return b.Bytes()
+}
+
+var palette = []string{"#1e87f0", "#f0506e", "#32d296", "#faa05a", "#8e44ad", "#222222"}
+
+// comparedMetric is one value charted for every compared lifter.
+type comparedMetric struct {
+	title, unit string
+	value       func(r *db.Result) decimal.Decimal
+	// zero is plotted when it is a real value, a 0% make rate unlike a
+	// bombed out total
+	zero bool
+}
+
+var comparedMetrics = []comparedMetric{
+	{"Totals", "kg", func(r *db.Result) decimal.Decimal { return r.Total }, false},
+	{"Best snatch", "kg", func(r *db.Result) decimal.Decimal { return r.BestSN }, false},
+	{"Best clean & jerk", "kg", func(r *db.Result) decimal.Decimal { return r.BestCJ }, false},
+	{"Make rate", "%", func(r *db.Result) decimal.Decimal {
+		return r.SNSMade.Add(r.CJSMade).Mul(decimal.New(100, 0)).DivRound(decimal.New(6, 0), 1)
+	}, true},
+}
+
+// Comparison charts each lifter's totals, best lifts and make rate at every
+// meet, one chart per metric so each gets its own scale. Every chart covers
+// the same dates so they line up.
+func Comparison(summaries []*db.ResultsSummary) []*Chart {
+	var charts []*Chart
+	for _, m := range comparedMetrics {
+		c := &Chart{Title: m.title, YLabel: m.unit}
+		for i, rs := range summaries {
+			s := Series{Name: rs.Lifter, Color: palette[i%len(palette)]}
+			for _, r := range rs.Results {
+				d, err := time.Parse("2006-01-02", r.Date)
+				if err != nil {
+					continue
+				}
+				f, _ := m.value(r).Float64()
+				if f < 0 || (f == 0 && !m.zero) {
+					continue
+				}
+				s.Points = append(s.Points, Point{Date: d, Value: f, Label: fmt.Sprintf("%v %v: %v %v (%v)", rs.Lifter, r.Date, f, m.unit, r.MeetName)})
+			}
+			sort.Slice(s.Points, func(i, j int) bool { return s.Points[i].Date.Before(s.Points[j].Date) })
+			c.Series = append(c.Series, s)
+		}
+		charts = append(charts, c)
+	}
+	first, last := timeline(charts)
+	for _, c := range charts {
+		c.From, c.To = first, last
+	}
+	return charts
+}
+
+// timeline returns the first and last date charted on any of charts.
+func timeline(charts []*Chart) (time.Time, time.Time) {
+	var first, last time.Time
+	for _, c := range charts {
+		f, l, _, ok := c.bounds()
+		if !ok {
+			continue
+		}
+		if first.IsZero() || f.Before(first) {
+			first = f
+		}
+		if l.After(last) {
+			last = l
+		}
+	}
+	return first, last
+}
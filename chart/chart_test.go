@@ -0,0 +1,100 @@
+package chart
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+func result(date, sn, cj, total, bw string) *db.Result {
+	return &db.Result{
+		Date:              date,
+		MeetName:          "Meet <" + date + ">",
+		BestSN:            decimal.RequireFromString(sn),
+		BestCJ:            decimal.RequireFromString(cj),
+		Total:             decimal.RequireFromString(total),
+		CompetitionWeight: decimal.RequireFromString(bw),
+	}
+}
+
+func TestProgression(t *testing.T) {
+	rs := &db.ResultsSummary{
+		Lifter:   "Chris Wolfe",
+		Hometown: "Austin, TX",
+		Results: []*db.Result{
+			result("2018-06-20", "82", "110", "192", "80.2"),
+			result("2017-06-17", "85", "0", "0", "76.9"),
+			result("2017-03-04", "80", "100", "180", "76.4"),
+			result("not a date", "1", "1", "2", "1"),
+		},
+	}
+	c := Progression(rs)
+	assert.Len(t, c.Series, 4)
+
+	total := c.Series[0]
+	assert.Len(t, total.Points, 2, "bomb outs aren't plotted")
+	assert.Equal(t, 180.0, total.Points[0].Value, "points are oldest first")
+	assert.Equal(t, 192.0, total.Points[1].Value)
+	assert.Len(t, c.Series[2].Points, 3)
+}
+
+func TestSVGIsWellFormed(t *testing.T) {
+	rs := &db.ResultsSummary{
+		Lifter:   "D'Angelo & Co",
+		Hometown: "Vallejo, CA",
+		Results:  []*db.Result{result("2018-06-20", "82", "110", "192", "80.2"), result("2016-01-01", "80", "100", "180", "76.4")},
+	}
+	out := Progression(rs).SVG()
+	d := xml.NewDecoder(strings.NewReader(string(out)))
+	for {
+		_, err := d.Token()
+		if err != nil {
+			assert.Equal(t, "EOF", err.Error(), "svg should parse as xml")
+			break
+		}
+	}
+	assert.Contains(t, string(out), "D&#39;Angelo &amp; Co")
+	assert.Contains(t, string(out), ">2017<", "years are labeled")
+}
+
+func TestSVGEmpty(t *testing.T) {
+	out := Progression(&db.ResultsSummary{}).SVG()
+	assert.Contains(t, string(out), "No results to chart")
+}
+
+func TestNiceMax(t *testing.T) {
+	assert.Equal(t, 250.0, niceMax(192))
+	assert.Equal(t, 100.0, niceMax(85))
+	assert.Equal(t, 1.0, niceMax(0))
+}
+
+func TestComparison(t *testing.T) {
+	made := result("2018-06-20", "82", "110", "192", "80.2")
+	made.SNSMade, made.CJSMade = decimal.New(3, 0), decimal.New(2, 0)
+	bombed := result("2016-01-01", "0", "0", "0", "76.4")
+	summaries := []*db.ResultsSummary{
+		{Lifter: "Chris Wolfe", Results: []*db.Result{made, bombed}},
+		{Lifter: "Kyle Brown", Results: []*db.Result{result("2017-03-04", "80", "100", "180", "76.4")}},
+	}
+	charts := Comparison(summaries)
+	var titles []string
+	for _, c := range charts {
+		titles = append(titles, c.Title)
+		assert.Len(t, c.Series, 2, "every lifter is on every chart")
+		first, last, _, _ := c.bounds()
+		assert.Equal(t, "2016-01-01", first.Format("2006-01-02"), "%v shares the timeline", c.Title)
+		assert.Equal(t, "2018-06-20", last.Format("2006-01-02"), "%v shares the timeline", c.Title)
+	}
+	assert.Equal(t, []string{"Totals", "Best snatch", "Best clean & jerk", "Make rate"}, titles)
+
+	assert.Len(t, charts[0].Series[0].Points, 1, "bomb outs aren't plotted")
+	rates := charts[3].Series[0].Points
+	if assert.Len(t, rates, 2, "missing every attempt is a make rate") {
+		assert.Equal(t, 0.0, rates[0].Value)
+		assert.Equal(t, 83.3, rates[1].Value)
+	}
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// AttemptRate is how often a lifter makes one attempt number.
+type AttemptRate struct {
+	Attempt int             `json:"attempt"`
+	Taken   int             `json:"taken"`
+	Made    int             `json:"made"`
+	Percent decimal.Decimal `json:"percent"`
+}
+
+// LiftAnalytics breaks down the attempts at one lift across every meet.
+type LiftAnalytics struct {
+	// Attempts holds the first, second and third attempts in order
+	Attempts []AttemptRate `json:"attempts"`
+	// AvgJump is the average kg added between consecutive attempts
+	AvgJump decimal.Decimal `json:"avg_jump"`
+	// AvgOpenerPercent is the opener as a percentage of the best made at
+	// earlier meets, meets without an earlier best are skipped
+	AvgOpenerPercent decimal.Decimal `json:"avg_opener_percent"`
+	// BombOuts counts meets where every attempt taken was missed
+	BombOuts       int             `json:"bomb_outs"`
+	BombOutPercent decimal.Decimal `json:"bomb_out_percent"`
+}
+
+// AttemptAnalytics summarises a lifter's attempts at every meet.
+type AttemptAnalytics struct {
+	Meets     int           `json:"meets"`
+	Snatch    LiftAnalytics `json:"snatch"`
+	CleanJerk LiftAnalytics `json:"cleanjerk"`
+	// BombOuts counts meets without a total because either lift bombed
+	BombOuts       int             `json:"bomb_outs"`
+	BombOutPercent decimal.Decimal `json:"bomb_out_percent"`
+}
+
+var hundred = decimal.New(100, 0)
+
+// percent returns part/whole as a percentage rounded to one place, zero when
+// whole is zero.
+func percent(part, whole decimal.Decimal) decimal.Decimal {
+	if whole.IsZero() {
+		return decimal.Zero
+	}
+	return part.Mul(hundred).DivRound(whole, 1)
+}
+
+// liftTracker accumulates LiftAnalytics one meet at a time, oldest first.
+type liftTracker struct {
+	taken, made              [3]int
+	jumps, openers           int
+	jumpTotal, openerPercent decimal.Decimal
+	meets, bombOuts          int
+	best                     decimal.Decimal
+}
+
+// add records one meet's attempts and returns whether the lift bombed.
+func (t *liftTracker) add(attempts ...decimal.Decimal) bool {
+	var prev, opener decimal.Decimal
+	taken, made := 0, 0
+	for i, a := range attempts {
+		if a.IsZero() {
+			continue
+		}
+		taken++
+		t.taken[i]++
+		if a.Sign() > 0 {
+			made++
+			t.made[i]++
+		}
+		if opener.IsZero() {
+			opener = a.Abs()
+		}
+		if !prev.IsZero() {
+			t.jumps++
+			t.jumpTotal = t.jumpTotal.Add(a.Abs().Sub(prev.Abs()))
+		}
+		prev = a
+	}
+	if taken == 0 {
+		return false
+	}
+	t.meets++
+	if t.best.Sign() > 0 {
+		t.openers++
+		t.openerPercent = t.openerPercent.Add(percent(opener, t.best))
+	}
+	t.best = maxDec(t.best, bestAttempt(attempts...))
+	if made == 0 {
+		t.bombOuts++
+		return true
+	}
+	return false
+}
+
+func (t *liftTracker) analytics() LiftAnalytics {
+	la := LiftAnalytics{
+		BombOuts:       t.bombOuts,
+		BombOutPercent: percent(decimal.New(int64(t.bombOuts), 0), decimal.New(int64(t.meets), 0)),
+	}
+	for i := range t.taken {
+		la.Attempts = append(la.Attempts, AttemptRate{
+			Attempt: i + 1,
+			Taken:   t.taken[i],
+			Made:    t.made[i],
+			Percent: percent(decimal.New(int64(t.made[i]), 0), decimal.New(int64(t.taken[i]), 0)),
+		})
+	}
+	if t.jumps > 0 {
+		la.AvgJump = t.jumpTotal.DivRound(decimal.New(int64(t.jumps), 0), 2)
+	}
+	if t.openers > 0 {
+		la.AvgOpenerPercent = t.openerPercent.DivRound(decimal.New(int64(t.openers), 0), 1)
+	}
+	return la
+}
+
+// AnalyzeAttempts computes attempt analytics over results in any order.
+func AnalyzeAttempts(results []*Result) *AttemptAnalytics {
+	// openers are compared with earlier meets so walk oldest first
+	ordered := make([]*Result, len(results))
+	copy(ordered, results)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Date < ordered[j].Date })
+
+	aa := &AttemptAnalytics{Meets: len(results)}
+	var sn, cj liftTracker
+	for _, r := range ordered {
+		snBomb := sn.add(r.SN1, r.SN2, r.SN3)
+		cjBomb := cj.add(r.CJ1, r.CJ2, r.CJ3)
+		if snBomb || cjBomb {
+			aa.BombOuts++
+		}
+	}
+	aa.Snatch = sn.analytics()
+	aa.CleanJerk = cj.analytics()
+	aa.BombOutPercent = percent(decimal.New(int64(aa.BombOuts), 0), decimal.New(int64(aa.Meets), 0))
+	return aa
+}
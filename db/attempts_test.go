@@ -0,0 +1,38 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func attempts(date string, sn, cj []string) *Result {
+	s, c := lifts(sn...), lifts(cj...)
+	return &Result{Date: date, SN1: s[0], SN2: s[1], SN3: s[2], CJ1: c[0], CJ2: c[1], CJ3: c[2]}
+}
+
+func TestAnalyzeAttempts(t *testing.T) {
+	// newest first, as QueryResults returns them
+	results := []*Result{
+		attempts("2018-06-01", []string{"-90", "-90", "-90"}, []string{"110", "115", "-120"}),
+		attempts("2017-06-01", []string{"80", "-85", "85"}, []string{"100", "105", "0"}),
+	}
+	aa := AnalyzeAttempts(results)
+	assert.Equal(t, 2, aa.Meets)
+	assert.Equal(t, 1, aa.BombOuts)
+	assert.Equal(t, "50", aa.BombOutPercent.String())
+
+	assert.Equal(t, 1, aa.Snatch.Attempts[0].Made)
+	assert.Equal(t, 2, aa.Snatch.Attempts[0].Taken)
+	assert.Equal(t, "50", aa.Snatch.Attempts[0].Percent.String())
+	assert.Equal(t, 1, aa.Snatch.BombOuts)
+	// 80->85, 85->85, 90->90, 90->90
+	assert.Equal(t, "1.25", aa.Snatch.AvgJump.String())
+	// 90 opener over an 85 best
+	assert.Equal(t, "105.9", aa.Snatch.AvgOpenerPercent.String())
+
+	assert.Equal(t, 1, aa.CleanJerk.Attempts[2].Taken, "passed attempts aren't taken")
+	assert.Equal(t, 0, aa.CleanJerk.BombOuts)
+	// 110 opener over a 105 best
+	assert.Equal(t, "104.8", aa.CleanJerk.AvgOpenerPercent.String())
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// ComparedLifter holds the headline numbers for one lifter in a comparison.
+type ComparedLifter struct {
+	Lifter       string          `json:"lifter"`
+	Hometown     string          `json:"hometown"`
+	Meets        int             `json:"meets"`
+	FirstMeet    string          `json:"first_meet"`
+	LastMeet     string          `json:"last_meet"`
+	BestSN       decimal.Decimal `json:"best_sn"`
+	BestCJ       decimal.Decimal `json:"best_cj"`
+	BestTotal    decimal.Decimal `json:"best_total"`
+	BestSinclair decimal.Decimal `json:"best_sinclair"`
+	AvgSNMakes   decimal.Decimal `json:"avg_sn_makes"`
+	AvgCJMakes   decimal.Decimal `json:"avg_cj_makes"`
+	RecentWeight decimal.Decimal `json:"recent_weight"`
+}
+
+// SharedMeet is a meet entered by at least two of the compared lifters.
+// Entries are placed against each other rather than the whole field.
+type SharedMeet struct {
+	MeetName string       `json:"meet_name"`
+	Date     string       `json:"date"`
+	Entries  []*MeetEntry `json:"entries"`
+}
+
+// Comparison puts several lifters side by side.
+type Comparison struct {
+	Lifters     []*ComparedLifter `json:"lifters"`
+	SharedMeets []*SharedMeet     `json:"shared_meets"`
+	// Timeline is every result of every lifter, oldest first
+	Timeline []*Result `json:"timeline"`
+	// Summaries are kept for charting and aren't serialized
+	Summaries []*ResultsSummary `json:"-"`
+}
+
+// Compare builds a comparison from each lifter's results summary.
+func Compare(summaries []*ResultsSummary) *Comparison {
+	c := &Comparison{Summaries: summaries}
+	type meetKey struct{ name, date string }
+	meets := map[meetKey]*SharedMeet{}
+	var order []meetKey
+
+	for _, rs := range summaries {
+		cl := &ComparedLifter{
+			Lifter:       rs.Lifter,
+			Hometown:     rs.Hometown,
+			Meets:        len(rs.Results),
+			BestSN:       rs.BestSN,
+			BestCJ:       rs.BestCJ,
+			BestTotal:    rs.BestTotal,
+			BestSinclair: rs.BestSinclair,
+			AvgSNMakes:   rs.AvgSNMakes,
+			AvgCJMakes:   rs.AvgCJMakes,
+			RecentWeight: rs.RecentWeight,
+		}
+		for _, r := range rs.Results {
+			if cl.FirstMeet == "" || r.Date < cl.FirstMeet {
+				cl.FirstMeet = r.Date
+			}
+			if r.Date > cl.LastMeet {
+				cl.LastMeet = r.Date
+			}
+			c.Timeline = append(c.Timeline, r)
+
+			k := meetKey{r.MeetName, r.Date}
+			m, ok := meets[k]
+			if !ok {
+				m = &SharedMeet{MeetName: r.MeetName, Date: r.Date}
+				meets[k] = m
+				order = append(order, k)
+			}
+			m.Entries = append(m.Entries, &MeetEntry{Result: r})
+		}
+		c.Lifters = append(c.Lifters, cl)
+	}
+
+	for _, k := range order {
+		m := meets[k]
+		if len(m.Entries) < 2 {
+			continue
+		}
+		placeEntries(m.Entries)
+		c.SharedMeets = append(c.SharedMeets, m)
+	}
+	sort.SliceStable(c.SharedMeets, func(i, j int) bool {
+		return c.SharedMeets[i].Date > c.SharedMeets[j].Date
+	})
+	sort.SliceStable(c.Timeline, func(i, j int) bool {
+		return c.Timeline[i].Date < c.Timeline[j].Date
+	})
+	return c
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	chris, err := db.QueryResults("Chris Wolfe", "Austin, TX")
+	assert.Nil(t, err)
+	dangelo, err := db.QueryResults("D'Angelo Osorio", "Vallejo, CA")
+	assert.Nil(t, err)
+
+	c := Compare([]*ResultsSummary{chris, dangelo})
+	assert.Len(t, c.Lifters, 2)
+	assert.Equal(t, 3, c.Lifters[0].Meets)
+	assert.Equal(t, "2017-03-04", c.Lifters[0].FirstMeet)
+	assert.Equal(t, "192", c.Lifters[0].BestTotal.String())
+
+	assert.Len(t, c.SharedMeets, 2)
+	assert.Equal(t, "USA Weightlifting National Championships", c.SharedMeets[0].MeetName, "shared meets are most recent first")
+	for _, m := range c.SharedMeets {
+		assert.Len(t, m.Entries, 2)
+		assert.Equal(t, "D'Angelo Osorio", m.Entries[0].Lifter)
+		assert.Equal(t, 1, m.Entries[0].Place)
+		assert.Equal(t, 2, m.Entries[1].Place)
+	}
+
+	assert.Len(t, c.Timeline, 5)
+	assert.Equal(t, "2017-03-04", c.Timeline[0].Date, "the timeline is oldest first")
+}
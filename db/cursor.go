@@ -0,0 +1,98 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor is returned for cursors this server didn't hand out.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor marks the last lifter of a page. Search results are ordered by
+// score, then by the canonical name and hometown aliases are merged into, so
+// the cursor is a stable key and a page picks up after it even when the
+// lifter it names has since been merged away.
+type cursor struct {
+	Name     string  `json:"n"`
+	Hometown string  `json:"h"`
+	Score    float64 `json:"s"`
+}
+
+func encodeCursor(l Lifter) string {
+	b, _ := json.Marshal(cursor{Name: l.Name, Hometown: l.Hometown, Score: l.Score})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.Name == "" {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// after adds the condition selecting the search matches that come after the
+// cursor to w, comparing text the way searches are ordered.
+func (c cursor) after(w *where, d dialect) string {
+	score, name := w.next(c.Score), w.next(c.Name)
+	return `score < ` + score + ` OR (score = ` + score + ` AND (` + d.orderBy("lifter") + ` > ` + name +
+		` OR (lifter = ` + name + ` AND ` + d.orderBy("hometown") + ` > ` + w.next(c.Hometown) + `)))`
+}
+
+// QueryNamesAfter is QueryNames paged by cursor rather than page number. An
+// empty cursor returns the first page, each page's NextCursor the one after.
+// Pages don't shift when earlier pages change. Nothing is counted, so Total,
+// TotalPages, Current and Pages are left empty.
+func (o *OurDB) QueryNamesAfter(name, after string) (*LiftersResponse, error) {
+	logQuery("name: %v, cursor: %v\n", name, after)
+	var c cursor
+	if after != "" {
+		decoded, err := decodeCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		c = decoded
+	}
+	resp := &LiftersResponse{Name: name}
+	w := &where{}
+	matches := o.lifterMatches(w, name)
+	if matches == "" {
+		return resp, nil
+	}
+	q := `SELECT lifter, hometown, score FROM (` + matches + `) AS matches`
+	if after != "" {
+		q += ` WHERE ` + c.after(w, o.dialect)
+	}
+	// take one lifter past the page to know whether there is another
+	q += ` ORDER BY score DESC, ` + o.dialect.orderBy("lifter") + `, ` + o.dialect.orderBy("hometown") + ` LIMIT ` + w.next(pageLimit+1)
+	rows, err := o.db.Query(q, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l Lifter
+		if err := rows.Scan(&l.Name, &l.Hometown, &l.Score); err != nil {
+			return nil, err
+		}
+		resp.Lifters = append(resp.Lifters, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if int64(len(resp.Lifters)) > pageLimit {
+		resp.Lifters = resp.Lifters[:pageLimit]
+		resp.NextCursor = encodeCursor(resp.Lifters[pageLimit-1])
+	}
+	resp.Lifters, err = o.listHometowns(resp.Lifters)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
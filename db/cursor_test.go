@@ -0,0 +1,160 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryNamesAfter(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	first, err := db.QueryNamesAfter("steph", "")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), first.Total, "cursor pages aren't counted")
+	assert.Len(t, first.Lifters, 50)
+	assert.NotEqual(t, "", first.NextCursor)
+
+	second, err := db.QueryNamesAfter("steph", first.NextCursor)
+	assert.Nil(t, err)
+	assert.Len(t, second.Lifters, 6)
+	assert.Equal(t, "", second.NextCursor, "the last page has no cursor")
+
+	// pages match numbered pages
+	paged, err := db.QueryNames("steph", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, paged.Lifters, first.Lifters)
+	assert.Equal(t, first.NextCursor, paged.NextCursor)
+	paged, err = db.QueryNames("steph", "2")
+	assert.Nil(t, err)
+	assert.Equal(t, paged.Lifters, second.Lifters)
+	assert.Equal(t, "", paged.NextCursor)
+}
+
+func TestQueryNamesAfterMissingLifter(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	all, _, err := db.searchLifters("steph", 0, 0)
+	assert.Nil(t, err)
+
+	// a cursor naming a lifter no longer in the results picks up at the
+	// next lifter in search order
+	gone := all[9]
+	gone.Name += " Jr"
+	r, err := db.QueryNamesAfter("steph", encodeCursor(gone))
+	assert.Nil(t, err)
+	assert.Equal(t, all[10:], r.Lifters)
+}
+
+func TestQueryNamesAfterMergedAlias(t *testing.T) {
+	result := func(lifter, hometown string) *Result {
+		return &Result{
+			Date:              "2020-01-01",
+			MeetName:          "Meet",
+			Lifter:            lifter,
+			Hometown:          hometown,
+			Weightclass:       "Men's 85Kg",
+			CompetitionWeight: decimal.New(84, 0),
+			BestSN:            decimal.New(100, 0),
+			BestCJ:            decimal.New(120, 0),
+			Total:             decimal.New(220, 0),
+			URL:               "https://example.com/meet/" + lifter + "/" + hometown,
+		}
+	}
+	var results []*Result
+	for i := 0; i < 51; i++ {
+		results = append(results, result("John Smith", fmt.Sprintf("Town %02d", i)))
+	}
+	results = append(results, result("Johnathan Smith", "Elsewhere"), result("Jon Smith", "Town 99"))
+	db, err := NewMemoryDB(results)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	before, err := db.QueryNamesAfter("john smith", "")
+	assert.Nil(t, err)
+	assert.Equal(t, Lifter{Name: "John Smith", Hometown: "Town 00", Score: 1}, before.Lifters[0])
+
+	// John Smith from Town 00 tops the first page until it is merged into a
+	// lower scoring name, which then takes its score and sorts after every
+	// other John Smith. Jon Smith goes with it.
+	canonical := Lifter{Name: "Johnathan Smith", Hometown: "Elsewhere"}
+	assert.Nil(t, db.MergeLifters(Lifter{Name: "John Smith", Hometown: "Town 00"}, canonical))
+	assert.Nil(t, db.MergeLifters(Lifter{Name: "Jon Smith", Hometown: "Town 99"}, canonical))
+
+	var paged []Lifter
+	seen := map[identity]bool{}
+	page, err := db.QueryNamesAfter("john smith", "")
+	assert.Nil(t, err)
+	for {
+		for _, l := range page.Lifters {
+			i := identity{l.Name, l.Hometown}
+			assert.False(t, seen[i], "%v from %v is on two pages", l.Name, l.Hometown)
+			seen[i] = true
+		}
+		paged = append(paged, page.Lifters...)
+		if page.NextCursor == "" {
+			break
+		}
+		page, err = db.QueryNamesAfter("john smith", page.NextCursor)
+		assert.Nil(t, err)
+	}
+	all, _, err := db.searchLifters("john smith", 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, all, paged)
+	assert.Len(t, paged, 51)
+	assert.Equal(t, "Town 50", paged[49].Hometown)
+	assert.Equal(t, "Johnathan Smith", paged[50].Name)
+	assert.Equal(t, 1.0, paged[50].Score)
+	assert.Equal(t, []string{"Elsewhere", "Town 00", "Town 99"}, paged[50].Hometowns)
+
+	// a cursor handed out before the merge carries on without skipping
+	after, err := db.QueryNamesAfter("john smith", before.NextCursor)
+	assert.Nil(t, err)
+	assert.Equal(t, paged[49:], after.Lifters)
+}
+
+func TestQueryNamesAfterInvalidCursor(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	for _, c := range []string{"!!!", "bm90IGpzb24", "e30"} {
+		_, err := db.QueryNamesAfter("steph", c)
+		assert.Equal(t, ErrInvalidCursor, err, c)
+	}
+}
+
+func TestWindowPages(t *testing.T) {
+	display := func(pages []PageInfo) ([]int, []int) {
+		var shown, gaps []int
+		for _, p := range pages {
+			shown = append(shown, p.Display)
+			if p.Gap {
+				gaps = append(gaps, p.Display)
+			}
+		}
+		return shown, gaps
+	}
+
+	shown, gaps := display(windowPages(1, 1))
+	assert.Equal(t, []int{1}, shown)
+	assert.Nil(t, gaps)
+
+	shown, gaps = display(windowPages(1, 20))
+	assert.Equal(t, []int{1, 2, 3, 4, 20}, shown)
+	assert.Equal(t, []int{20}, gaps)
+
+	shown, gaps = display(windowPages(10, 20))
+	assert.Equal(t, []int{1, 7, 8, 9, 10, 11, 12, 13, 20}, shown)
+	assert.Equal(t, []int{7, 20}, gaps)
+
+	shown, gaps = display(windowPages(5, 8))
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8}, shown)
+	assert.Nil(t, gaps)
+}
@@ -2,64 +2,107 @@ package db
 
 import (
 	"database/sql"
-	"fmt"
-	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/lib/pq"
 	"github.com/shopspring/decimal"
-	"log"
 	"math"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// OurDB is a Store backed by a sqlite file, an in memory sqlite database or
+// postgres.
 type OurDB struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect dialect
+	// nameIndex is set once hasNameIndex finds lifter_names
+	nameIndexOnce sync.Once
+	nameIndex     bool
+	// memberStmt is memberResults, prepared by the first QueryResults
+	memberMu   sync.Mutex
+	memberStmt *sql.Stmt
 }
 
-func BuildDB(dbPath string) (*OurDB, error) {
-	// mark the connection as read only!
+// BuildDB opens the database for serving. Databases missing migrations are
+// refused with a *SchemaTooOldError.
+func BuildDB(dsn string) (*OurDB, error) {
+	o, err := OpenDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.CheckSchema(); err != nil {
+		o.Close()
+		return nil, err
+	}
+	return o, nil
+}
 
-	// make sure this has been run!
-	// create index idx_lifter_hometown on results(lifter, hometown);
-	db, err := sql.Open("sqlite3", dbPath)
+// OpenDB opens the database without checking its schema version, for tools
+// that create or migrate it. dsn is either a sqlite path or a postgres:// URL.
+func OpenDB(dsn string) (*OurDB, error) {
+	d, source := parseDSN(dsn)
+	db, err := sql.Open(d.driver(), source)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	return &OurDB{
-		db: db,
+		db:      db,
+		dialect: d,
 	}, nil
 }
 
 func (o *OurDB) Close() {
+	if o.memberStmt != nil {
+		o.memberStmt.Close()
+	}
 	o.db.Close()
 }
 
+// Ping checks the results table can be read.
+func (o *OurDB) Ping() error {
+	var one int
+	err := o.db.QueryRow(`SELECT 1 FROM results LIMIT 1`).Scan(&one)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
 type Lifter struct {
-	Name     string
-	Hometown string
+	Name     string `json:"name"`
+	Hometown string `json:"hometown"`
+	// Score is how well the lifter matched a name search, from 0 to 1
+	Score float64 `json:"score"`
+	// Hometowns lists every hometown of a lifter merged from several
+	// identities, it is empty otherwise
+	Hometowns []string `json:"hometowns,omitempty"`
 }
 
 type Result struct {
-	Date              string
-	MeetName          string
-	Lifter            string
-	Weightclass       string
-	CompetitionWeight decimal.Decimal
-	Hometown          string
-	CJ1               decimal.Decimal
-	CJ2               decimal.Decimal
-	CJ3               decimal.Decimal
-	SN1               decimal.Decimal
-	SN2               decimal.Decimal
-	SN3               decimal.Decimal
-	Total             decimal.Decimal
-	BestCJ            decimal.Decimal
-	BestSN            decimal.Decimal
-	URL               string
-	CJSMade           decimal.Decimal
-	SNSMade           decimal.Decimal
-	BestResult        bool
+	Date              string          `json:"date"`
+	MeetName          string          `json:"meet_name"`
+	Lifter            string          `json:"lifter"`
+	Weightclass       string          `json:"weight_class"`
+	CompetitionWeight decimal.Decimal `json:"competition_weight"`
+	Hometown          string          `json:"hometown"`
+	CJ1               decimal.Decimal `json:"cj1"`
+	CJ2               decimal.Decimal `json:"cj2"`
+	CJ3               decimal.Decimal `json:"cj3"`
+	SN1               decimal.Decimal `json:"sn1"`
+	SN2               decimal.Decimal `json:"sn2"`
+	SN3               decimal.Decimal `json:"sn3"`
+	Total             decimal.Decimal `json:"total"`
+	BestCJ            decimal.Decimal `json:"best_cj"`
+	BestSN            decimal.Decimal `json:"best_sn"`
+	URL               string          `json:"url"`
+	CJSMade           decimal.Decimal `json:"cjs_made"`
+	SNSMade           decimal.Decimal `json:"sns_made"`
+	BestResult        bool            `json:"best_result"`
+	Sinclair          decimal.Decimal `json:"sinclair"`
+	Flags             []Flag          `json:"flags,omitempty"`
+	PRs               []PR            `json:"prs,omitempty"`
 }
 
 func (r *Result) missesToMakes() {
@@ -68,43 +111,62 @@ func (r *Result) missesToMakes() {
 }
 
 type ResultsSummary struct {
-	Lifter       string
-	IWFFirstName string
-	IWFLastName  string
-	Hometown     string
-	BestCJ       decimal.Decimal
-	BestSN       decimal.Decimal
-	BestTotal    decimal.Decimal
-	AvgCJMakes   decimal.Decimal
-	AvgSNMakes   decimal.Decimal
-	RecentWeight decimal.Decimal
-	Results      []*Result
+	Lifter       string            `json:"lifter"`
+	IWFFirstName string            `json:"iwf_first_name"`
+	IWFLastName  string            `json:"iwf_last_name"`
+	Hometown     string            `json:"hometown"`
+	Hometowns    []string          `json:"hometowns"`
+	BestCJ       decimal.Decimal   `json:"best_cj"`
+	BestSN       decimal.Decimal   `json:"best_sn"`
+	BestTotal    decimal.Decimal   `json:"best_total"`
+	BestSinclair decimal.Decimal   `json:"best_sinclair"`
+	AvgCJMakes   decimal.Decimal   `json:"avg_cj_makes"`
+	AvgSNMakes   decimal.Decimal   `json:"avg_sn_makes"`
+	RecentWeight decimal.Decimal   `json:"recent_weight"`
+	Attempts     *AttemptAnalytics `json:"attempts"`
+	PRHistory    []PR              `json:"pr_history"`
+	Results      []*Result         `json:"results"`
 }
 
 type PageInfo struct {
-	Display int
+	Display int `json:"display"`
+	// Gap is set when pages before this one are not linked
+	Gap bool `json:"gap,omitempty"`
 }
 
 type LiftersResponse struct {
-	Lifters    []Lifter
-	Name       string
-	Total      int64
-	Pages      []PageInfo
-	Current    int64
-	TotalPages int64
+	Lifters []Lifter `json:"lifters"`
+	Name    string   `json:"name"`
+	// Total, Pages, Current and TotalPages are only set for numbered pages
+	Total      int64      `json:"total"`
+	Pages      []PageInfo `json:"pages"`
+	Current    int64      `json:"current"`
+	TotalPages int64      `json:"total_pages"`
+	// NextCursor fetches the lifters after this page, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
+// QueryNames searches lifter names and hometowns, tolerating typos, accents
+// and word order. Lifters are ordered by how well they match.
 func (o *OurDB) QueryNames(name, offset string) (*LiftersResponse, error) {
-	log.Printf("name: %v, offset: %v\n", name, offset)
-	nameLike := "%" + strings.Replace(name, " ", "%", -1) + "%"
-
-	// get the number of results so we can compute pages. Max result number is 50 per page.
-	var total int64
-	err := o.db.QueryRow(`SELECT IFNULL(SUM(ct), 0) from (SELECT 1 as ct FROM results WHERE lifter like $1 GROUP BY hometown, lifter)`, nameLike).Scan(&total)
+	logQuery("name: %v, offset: %v\n", name, offset)
+	onum := parsePage(offset)
+	lifters, total, err := o.searchLifters(name, pageLimit, (onum-1)*pageLimit)
 	if err != nil {
-		fmt.Printf("cw: %v", err)
 		return nil, err
 	}
+	if len(lifters) == 0 && onum > 1 {
+		// past the last page, which is shown instead
+		if total, err = o.countLifters(name); err != nil {
+			return nil, err
+		}
+		if total > 0 {
+			onum, _, _ = pageRange(total, offset)
+			if lifters, _, err = o.searchLifters(name, pageLimit, (onum-1)*pageLimit); err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	// if we found nothing return nothing and stop
 	if total == 0 {
@@ -119,66 +181,57 @@ func (o *OurDB) QueryNames(name, offset string) (*LiftersResponse, error) {
 		return resp, nil
 	}
 
-	// get the offset
-	pageLimit := int64(50)
-	var onum int64
-	if len(offset) != 0 {
-		onum, err = strconv.ParseInt(offset, 10, 64)
-		if err != nil {
-			log.Printf("failed to parse offset %v", offset)
-			// go to page 0
-			onum = int64(1)
-		}
-	} else {
-		onum = int64(1)
-	}
-	totalThisPage := getPageSize(onum, total, pageLimit)
-
-	// page is meant to be min 1 for humans, offset is internal and should be 0-based
-	if onum >= 1 {
-		onum--
-	}
-	// get the names
-	rows, err := o.db.Query(`SELECT DISTINCT lifter, hometown FROM results WHERE lifter like $1 ORDER BY lifter ASC LIMIT $2 OFFSET $3`, nameLike, pageLimit, onum*pageLimit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	lifters := make([]Lifter, totalThisPage, totalThisPage)
-	ct := 0
-	for rows.Next() {
-		l := Lifter{}
-		err = rows.Scan(&l.Name, &l.Hometown)
-		if err != nil {
-			return nil, err
-		}
-		lifters[ct] = l
-		ct++
-	}
-	err = rows.Err()
-	if err != nil {
-		log.Print(err)
-		return nil, err
-	}
-
-	// there are 50 per page
-	numPages := total / pageLimit
-	pages := makePageInfoRange(int(numPages))
-
-	// total is the number of pages
-	// current is the page being returned, if this had an offset, it would be the next page
+	// current is the page being returned
+	onum, numPages, pages := pageRange(total, offset)
 	resp := &LiftersResponse{
 		Lifters:    lifters,
 		Total:      total,
-		Current:    onum + 1,
+		Current:    onum,
 		Name:       name,
 		Pages:      pages,
-		TotalPages: int64(len(pages)),
+		TotalPages: numPages,
+	}
+	if onum < numPages {
+		resp.NextCursor = encodeCursor(lifters[len(lifters)-1])
 	}
 	return resp, nil
 }
 
+// pageLimit is the number of rows shown on each page.
+const pageLimit = int64(50)
+
+// parsePage converts a 1-based page number from a query string, falling back
+// to the first page when it is missing or invalid.
+func parsePage(offset string) int64 {
+	if len(offset) == 0 {
+		return 1
+	}
+	onum, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil || onum < 1 {
+		logQuery("failed to parse offset %v", offset)
+		return 1
+	}
+	return onum
+}
+
+// pageWindow is how many pages either side of the current page are linked.
+const pageWindow = 3
+
+// pageRange returns the current page, clamped to the last page, the number
+// of pages and the page links for total rows. Only the first, last and pages
+// near the current one are linked.
+func pageRange(total int64, offset string) (int64, int64, []PageInfo) {
+	onum := parsePage(offset)
+	numPages := int64(math.Ceil(float64(total) / float64(pageLimit)))
+	if numPages < 1 {
+		numPages = 1
+	}
+	if onum > numPages {
+		onum = numPages
+	}
+	return onum, numPages, windowPages(onum, numPages)
+}
+
 func getPageSize(pageNum, total, limit int64) int64 {
 	if pageNum < 1 {
 		panic("offset must be positive")
@@ -194,99 +247,119 @@ func getPageSize(pageNum, total, limit int64) int64 {
 	return rem
 }
 
+// memberResults reads every result of the athlete a lifter and hometown
+// belong to, along with the athlete's canonical identity. The identity is
+// resolved once in athlete and joined against the results of itself and the
+// identities merged into it; merges never chain, so that is all there is.
+const memberResults = `WITH athlete AS (
+		SELECT COALESCE(a.canonical_lifter, q.lifter) AS lifter, COALESCE(a.canonical_hometown, q.hometown) AS hometown
+		FROM (SELECT CAST($1 AS TEXT) AS lifter, CAST($2 AS TEXT) AS hometown) AS q
+		LEFT JOIN lifter_aliases a ON a.lifter = q.lifter AND a.hometown = q.hometown
+	), members AS (
+		SELECT lifter, hometown FROM athlete
+		UNION ALL
+		SELECT a.lifter, a.hometown FROM athlete c JOIN lifter_aliases a ON a.canonical_lifter = c.lifter AND a.canonical_hometown = c.hometown
+	)
+	SELECT c.lifter, c.hometown, r.date, r.meet_name, r.lifter, r.weight_class, r.competition_weight, r.hometown, r.cj1, r.cj2, r.cj3, r.sn1, r.sn2, r.sn3, r.total, r.best_snatch, r.best_cleanjerk, r.url, r.sinclair
+	FROM athlete c CROSS JOIN members m
+	JOIN results r ON r.lifter = m.lifter AND r.hometown = m.hometown
+	ORDER BY r.date DESC`
+
+// QueryResults returns every result for a lifter. Identities merged with
+// the lifter are included and the summary is named for the canonical one.
+// Aliases are resolved and the rows read in a single query on the
+// (lifter, hometown) index, then summarized as they are scanned using the
+// Sinclair scores stored when the results were upserted.
 func (o *OurDB) QueryResults(name, hometown string) (*ResultsSummary, error) {
-	log.Printf("name: %v, hometown: %v\n", name, hometown)
-	// check results count
-	var resultCt int64
-	err := o.db.QueryRow(`SELECT IFNULL(SUM(ct), 0) from (SELECT 1 as ct FROM results WHERE lifter = $1 and hometown = $2)`, name, hometown).Scan(&resultCt)
+	logQuery("name: %v, hometown: %v\n", name, hometown)
+	stmt, err := o.memberResultsStmt()
 	if err != nil {
 		return nil, err
 	}
-
-	rows, err := o.db.Query(`SELECT date, meet_name, lifter, weight_class, competition_weight, hometown, cj1, cj2, cj3, sn1, sn2, sn3, total, best_snatch, best_cleanjerk, url FROM results WHERE lifter = $1 and hometown = $2 ORDER BY date DESC`, name, hometown)
+	rows, err := stmt.Query(name, hometown)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	// load the results
-	results := make([]*Result, resultCt, resultCt)
-
-	ct := 0
+	canonical := identity{name, hometown}
+	rs := &ResultsSummary{Lifter: name, Hometown: hometown}
+	var s summarizer
 	for rows.Next() {
 		r := &Result{}
-		err = rows.Scan(&r.Date, &r.MeetName, &r.Lifter, &r.Weightclass, &r.CompetitionWeight, &r.Hometown, &r.CJ1, &r.CJ2, &r.CJ3, &r.SN1, &r.SN2, &r.SN3, &r.Total, &r.BestSN, &r.BestCJ, &r.URL)
+		err = rows.Scan(&canonical.lifter, &canonical.hometown, &r.Date, &r.MeetName, &r.Lifter, &r.Weightclass, &r.CompetitionWeight, &r.Hometown, &r.CJ1, &r.CJ2, &r.CJ3, &r.SN1, &r.SN2, &r.SN3, &r.Total, &r.BestSN, &r.BestCJ, &r.URL, &r.Sinclair)
 		if err != nil {
 			return nil, err
 		}
-
-		// compute misses an makes
-		r.BestResult = false
 		r.missesToMakes()
-		results[ct] = r
-		ct++
+		r.Flags = r.Validate()
+		s.add(rs, r)
 	}
-	err = rows.Err()
-	if err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-
-	if len(results) == 0 {
-		return &ResultsSummary{Lifter: name, Hometown: hometown}, nil
+	if len(rs.Results) == 0 {
+		return rs, nil
 	}
+	s.finish(rs)
 
-	rs := ResultsSummary{Results: results}
-
-	// load the total
-	err = o.db.QueryRow(`select max(total) from results where lifter = $1 and hometown = $2`, name, hometown).Scan(&rs.BestTotal)
-	if err != nil {
-		return nil, err
+	rs.Lifter, rs.Hometown = canonical.lifter, canonical.hometown
+	rs.IWFFirstName, rs.IWFLastName = ToIWFName(canonical.lifter)
+	rs.Hometowns = []string{canonical.hometown}
+	for _, r := range rs.Results {
+		rs.Hometowns = appendMissing(rs.Hometowns, r.Hometown)
 	}
-	// load the best SN
-	err = o.db.QueryRow(`select max(best_snatch) from results where lifter = $1 and hometown = $2`, name, hometown).Scan(&rs.BestSN)
-	if err != nil {
-		return nil, err
-	}
-	// load the best CJ
-	err = o.db.QueryRow(`select max(best_cleanjerk) from results where lifter = $1 and hometown = $2`, name, hometown).Scan(&rs.BestCJ)
-	if err != nil {
-		return nil, err
-	}
-	// now compute avg CJ makes. Loop over the results, converting each to a 1 or -1
-	totalCJs := decimal.Zero
-	totalSNs := decimal.Zero
-
-	numLiftsBase := decimal.New(int64(len(results)*3), 1)
-
-	for _, r := range results {
-		// update the avg made
-		totalSNs = r.SNSMade.Add(totalSNs)
-		totalCJs = r.CJSMade.Add(totalCJs)
+	rs.Attempts = AnalyzeAttempts(rs.Results)
+	rs.PRHistory = markPRs(rs.Results)
+	return rs, nil
+}
 
-		// find the bests over the entire result set
-		if r.BestCJ.Equal(rs.BestCJ) {
-			r.BestResult = true
-		}
-		if r.BestSN.Equal(rs.BestSN) {
-			r.BestResult = true
-		}
-		if r.Total.Equal(rs.BestTotal) {
-			r.BestResult = true
+// memberResultsStmt prepares memberResults once, parsing it took about a
+// fifth of every QueryResults. A failed prepare is retried on the next call
+// in case the tables have not been migrated yet.
+func (o *OurDB) memberResultsStmt() (*sql.Stmt, error) {
+	o.memberMu.Lock()
+	defer o.memberMu.Unlock()
+	if o.memberStmt == nil {
+		stmt, err := o.db.Prepare(memberResults)
+		if err != nil {
+			return nil, err
 		}
+		o.memberStmt = stmt
 	}
-	factor := decimal.New(100, 1)
+	return o.memberStmt, nil
+}
 
-	rs.AvgSNMakes = totalSNs.DivRound(numLiftsBase, 5).Mul(factor)
-	rs.AvgCJMakes = totalCJs.DivRound(numLiftsBase, 5).Mul(factor)
+// summarizer accumulates the summary statistics of results added newest
+// first.
+type summarizer struct {
+	snsMade, cjsMade decimal.Decimal
+}
 
-	// we shouldn't get here if there are no results
-	rs.Lifter = results[0].Lifter
-	rs.IWFFirstName, rs.IWFLastName = ToIWFName(results[0].Lifter)
+func (s *summarizer) add(rs *ResultsSummary, r *Result) {
+	if len(rs.Results) == 0 {
+		rs.RecentWeight = r.CompetitionWeight
+	}
+	rs.Results = append(rs.Results, r)
+	rs.BestTotal = maxDec(rs.BestTotal, r.Total)
+	rs.BestSN = maxDec(rs.BestSN, r.BestSN)
+	rs.BestCJ = maxDec(rs.BestCJ, r.BestCJ)
+	rs.BestSinclair = maxDec(rs.BestSinclair, r.Sinclair)
+	s.snsMade = s.snsMade.Add(r.SNSMade)
+	s.cjsMade = s.cjsMade.Add(r.CJSMade)
+}
 
-	rs.Hometown = results[0].Hometown
-	rs.RecentWeight = results[0].CompetitionWeight
+// finish computes the make percentages and marks the results holding a
+// best lift, which can only be known once every result has been seen.
+func (s *summarizer) finish(rs *ResultsSummary) {
+	attempts := decimal.New(int64(len(rs.Results)*3), 0)
+	hundred := decimal.New(100, 0)
+	rs.AvgSNMakes = s.snsMade.DivRound(attempts, 4).Mul(hundred)
+	rs.AvgCJMakes = s.cjsMade.DivRound(attempts, 4).Mul(hundred)
 
-	return &rs, nil
+	for _, r := range rs.Results {
+		r.BestResult = r.BestCJ.Equal(rs.BestCJ) || r.BestSN.Equal(rs.BestSN) || r.Total.Equal(rs.BestTotal)
+	}
 }
 
 func max(x, y int) int {
@@ -303,15 +376,19 @@ func maxDec(x, y decimal.Decimal) decimal.Decimal {
 	return y
 }
 
-func makePageInfoRange(max int) []PageInfo {
-	// pagination always starts at 1
-	a := make([]PageInfo, max+1)
-	for i := range a {
-		a[i] = PageInfo{
-			Display: i + 1,
+// windowPages links the first and last pages and those within pageWindow of
+// current. Pages after a skipped run are marked as a gap.
+func windowPages(current, numPages int64) []PageInfo {
+	var pages []PageInfo
+	last := int64(0)
+	for p := int64(1); p <= numPages; p++ {
+		if p != 1 && p != numPages && (p < current-pageWindow || p > current+pageWindow) {
+			continue
 		}
+		pages = append(pages, PageInfo{Display: int(p), Gap: p != last+1})
+		last = p
 	}
-	return a
+	return pages
 }
 
 var nameReg = regexp.MustCompile("[^a-z ]+")
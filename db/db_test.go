@@ -1,20 +1,25 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/scoring"
 	"io/ioutil"
 	"log"
+	"strings"
 	"testing"
 )
 
 func TestQueryNames(t *testing.T) {
-	db, err := BuildDB("../results.db")
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
 	defer db.Close()
 
 	assert.Nil(t, err, "failed to build db")
 
-	// this relies on data in the DB!
+	// this relies on data in the fixtures!
 	r, err := db.QueryNames("francisco flores", "1")
 
 	assert.Nil(t, err, "query for names returned an error")
@@ -22,12 +27,12 @@ func TestQueryNames(t *testing.T) {
 }
 
 func TestQueryResults(t *testing.T) {
-	db, err := BuildDB("../results.db")
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
 	defer db.Close()
 
 	assert.Nil(t, err, "failed to build db")
 
-	// this relies on data in the DB!
+	// this relies on data in the fixtures!
 	r, err := db.QueryNames("chris wolfe", "1")
 	assert.Nil(t, err, "query for names returned an error")
 	assert.NotEmpty(t, r, "no names returned")
@@ -43,24 +48,24 @@ func TestQueryResults(t *testing.T) {
 }
 
 func TestQueryNamesRegression(t *testing.T) {
-	db, err := BuildDB("../results.db")
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
 	defer db.Close()
 
 	assert.Nil(t, err, "failed to build db")
 
-	// this relies on data in the DB!
+	// this relies on data in the fixtures!
 	r, err := db.QueryNames("mos", "1")
 	assert.Nil(t, err, "query for names returned an error")
 	assert.NotEmpty(t, r, "no names returned")
 }
 
 func TestQueryResultsLikeReplace(t *testing.T) {
-	db, err := BuildDB("../results.db")
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
 	defer db.Close()
 
 	assert.Nil(t, err, "failed to build db")
 
-	// this relies on data in the DB!
+	// this relies on data in the fixtures!
 	r, err := db.QueryNames("j bradley", "1")
 	assert.Nil(t, err, "query for names returned an error")
 	assert.NotEmpty(t, r.Lifters, "no names returned")
@@ -75,29 +80,29 @@ func TestQueryResultsLikeReplace(t *testing.T) {
 }
 
 func TestQueryCountAccurate(t *testing.T) {
-	db, err := BuildDB("../results.db")
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
 	defer db.Close()
 
 	assert.Nil(t, err, "failed to build db")
 
-	// this relies on data in the DB!
+	// this relies on data in the fixtures!
 	r, err := db.QueryNames("kyle brown", "1")
 	assert.Nil(t, err, "query for names returned an error")
 
 	assert.Equal(t, len(r.Lifters), 2, "two r not returned for kyle brown")
 	assert.Equal(t, r.Total, int64(2), "the total was not two")
 
-	// this relies on data in the DB!
+	// this relies on data in the fixtures!
 	r, err = db.QueryNames("steph", "1")
 	assert.Nil(t, err, "query for names returned an error")
 
-	// this could be flaky but should be at least 300 lifters
+	// the fixtures hold more than a page of steph lifters
 	assert.Equal(t, len(r.Lifters), 50, "two r not returned for kyle brown")
 	assert.True(t, r.Total > 50, "the total was not larger than a page boundary")
 }
 
 func TestQueryWorksWhenZero(t *testing.T) {
-	db, err := BuildDB("../results.db")
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
 	defer db.Close()
 
 	assert.Nil(t, err, "failed to build db")
@@ -129,11 +134,30 @@ func TestIWFNames(t *testing.T) {
 	}
 }
 
+func TestResultJSONFieldNames(t *testing.T) {
+	r := Result{
+		Date:   "2018-06-20",
+		Lifter: "Chris Wolfe",
+		Total:  decimal.RequireFromString("170.5"),
+		BestSN: decimal.New(75, 0),
+	}
+	b, err := json.Marshal(r)
+	assert.Nil(t, err, "failed to marshal result")
+
+	var fields map[string]interface{}
+	assert.Nil(t, json.Unmarshal(b, &fields), "failed to unmarshal result")
+	assert.Equal(t, "2018-06-20", fields["date"])
+	assert.Equal(t, "Chris Wolfe", fields["lifter"])
+	// decimals are strings so clients don't lose precision
+	assert.Equal(t, "170.5", fields["total"])
+	assert.Equal(t, "75", fields["best_sn"])
+}
+
 var lifterResponseResult *LiftersResponse
 
 func BenchmarkNameQuery(b *testing.B) {
 	log.SetOutput(ioutil.Discard)
-	db, err := BuildDB("../results.db")
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
 	defer db.Close()
 	if err != nil {
 		panic("failed to setup DB")
@@ -149,7 +173,7 @@ var resultsResponse *ResultsSummary
 
 func BenchmarkResultsQuery(b *testing.B) {
 	log.SetOutput(ioutil.Discard)
-	db, err := BuildDB("../results.db")
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
 	defer db.Close()
 	if err != nil {
 		panic("failed to setup DB")
@@ -182,7 +206,7 @@ func TestGetPageSize(t *testing.T) {
 }
 
 func TestNoBadData(t *testing.T) {
-	db, err := BuildDB("../results.db")
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
 	log.SetOutput(ioutil.Discard)
 	defer db.Close()
 	if err != nil {
@@ -217,3 +241,220 @@ func TestNoBadData(t *testing.T) {
 		})
 	}
 }
+
+var (
+	syntheticFirstNames = []string{"Alex", "Chris", "Jordan", "Sam", "Taylor", "Morgan", "Casey", "Jamie", "Riley", "Avery", "Quinn", "Drew", "Mattie", "Kyle", "Jessie", "Stephen"}
+	syntheticSyllables  = []string{"ro", "ger", "wol", "fe", "bra", "dley", "os", "or", "io", "mar", "tin", "ez", "ka", "lo", "vic", "son"}
+)
+
+// syntheticLifter names the lth lifter of a synthetic database. Names share
+// first names and last name prefixes the way real ones do.
+func syntheticLifter(l int) string {
+	first := syntheticFirstNames[l%len(syntheticFirstNames)]
+	n := l / len(syntheticFirstNames)
+	last := ""
+	for i := 0; i < 3 || n > 0; i++ {
+		last += syntheticSyllables[n%len(syntheticSyllables)]
+		n /= len(syntheticSyllables)
+	}
+	return first + " " + strings.Title(last)
+}
+
+// syntheticDB holds the given number of lifters with meets results each, a
+// stand in for a full USAW export in benchmarks.
+func syntheticDB(lifters, meets int) (*OurDB, error) {
+	results := make([]*Result, 0, lifters*meets)
+	for l := 0; l < lifters; l++ {
+		for m := 0; m < meets; m++ {
+			sn := decimal.New(int64(60+l%60+m), 0)
+			cj := sn.Add(decimal.New(25, 0))
+			results = append(results, &Result{
+				Date:              fmt.Sprintf("%d-%02d-%02d", 2000+m%18, 1+m%12, 1+l%28),
+				MeetName:          fmt.Sprintf("Meet %d", m),
+				Lifter:            syntheticLifter(l),
+				Hometown:          fmt.Sprintf("Town %d", l%500),
+				Weightclass:       "Men's 85Kg",
+				CompetitionWeight: decimal.New(84, 0),
+				SN1:               sn.Sub(decimal.New(5, 0)),
+				SN2:               sn.Neg(),
+				SN3:               sn,
+				CJ1:               cj.Sub(decimal.New(5, 0)),
+				CJ2:               cj,
+				CJ3:               cj.Add(decimal.New(3, 0)).Neg(),
+				BestSN:            sn,
+				BestCJ:            cj,
+				Total:             sn.Add(cj),
+				URL:               fmt.Sprintf("https://example.com/meet/%d", m),
+			})
+		}
+	}
+	return NewMemoryDB(results)
+}
+
+// queryResultsSeparately reads a summary the way QueryResults used to: alias
+// lookups, a count query, the rows, a MAX query per best lift and then the
+// averages worked out in a second pass over the rows.
+func queryResultsSeparately(o *OurDB, name, hometown string) (*ResultsSummary, error) {
+	canonical := identity{name, hometown}
+	err := o.db.QueryRow(`SELECT canonical_lifter, canonical_hometown FROM lifter_aliases WHERE lifter = $1 AND hometown = $2`, name, hometown).Scan(&canonical.lifter, &canonical.hometown)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	members := []identity{canonical}
+	aliases, err := o.db.Query(`SELECT lifter, hometown FROM lifter_aliases WHERE canonical_lifter = $1 AND canonical_hometown = $2 ORDER BY lifter, hometown`, canonical.lifter, canonical.hometown)
+	if err != nil {
+		return nil, err
+	}
+	for aliases.Next() {
+		var i identity
+		if err := aliases.Scan(&i.lifter, &i.hometown); err != nil {
+			aliases.Close()
+			return nil, err
+		}
+		members = append(members, i)
+	}
+	aliases.Close()
+	if err := aliases.Err(); err != nil {
+		return nil, err
+	}
+
+	w := &where{}
+	clauses := make([]string, len(members))
+	args := make([]interface{}, 0, 2*len(members))
+	for i, m := range members {
+		clauses[i] = "(lifter = ? AND hometown = ?)"
+		args = append(args, m.lifter, m.hometown)
+	}
+	w.add("("+strings.Join(clauses, " OR ")+")", args...)
+
+	var resultCt int64
+	err = o.db.QueryRow(`SELECT COALESCE(SUM(ct), 0) from (SELECT 1 as ct FROM results`+w.String()+`) AS matches`, w.args...).Scan(&resultCt)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := o.db.Query(`SELECT date, meet_name, lifter, weight_class, competition_weight, hometown, cj1, cj2, cj3, sn1, sn2, sn3, total, best_snatch, best_cleanjerk, url FROM results`+w.String()+` ORDER BY date DESC`, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*Result, resultCt, resultCt)
+	ct := 0
+	for rows.Next() {
+		r := &Result{}
+		err = rows.Scan(&r.Date, &r.MeetName, &r.Lifter, &r.Weightclass, &r.CompetitionWeight, &r.Hometown, &r.CJ1, &r.CJ2, &r.CJ3, &r.SN1, &r.SN2, &r.SN3, &r.Total, &r.BestSN, &r.BestCJ, &r.URL)
+		if err != nil {
+			return nil, err
+		}
+		r.missesToMakes()
+		r.Sinclair = scoring.Sinclair(r.Total, r.CompetitionWeight, r.Date, r.Weightclass)
+		r.Flags = r.Validate()
+		results[ct] = r
+		ct++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return &ResultsSummary{Lifter: canonical.lifter, Hometown: canonical.hometown}, nil
+	}
+
+	rs := ResultsSummary{Results: results}
+	for _, m := range []struct {
+		col string
+		dst *decimal.Decimal
+	}{
+		{"total", &rs.BestTotal},
+		{"best_snatch", &rs.BestSN},
+		{"best_cleanjerk", &rs.BestCJ},
+	} {
+		if err := o.db.QueryRow(`select max(`+m.col+`) from results`+w.String(), w.args...).Scan(m.dst); err != nil {
+			return nil, err
+		}
+	}
+
+	totalCJs, totalSNs := decimal.Zero, decimal.Zero
+	numLiftsBase := decimal.New(int64(len(results)*3), 1)
+	for _, r := range results {
+		totalSNs = r.SNSMade.Add(totalSNs)
+		totalCJs = r.CJSMade.Add(totalCJs)
+		rs.BestSinclair = maxDec(rs.BestSinclair, r.Sinclair)
+		r.BestResult = r.BestCJ.Equal(rs.BestCJ) || r.BestSN.Equal(rs.BestSN) || r.Total.Equal(rs.BestTotal)
+	}
+	factor := decimal.New(100, 1)
+	rs.AvgSNMakes = totalSNs.DivRound(numLiftsBase, 5).Mul(factor)
+	rs.AvgCJMakes = totalCJs.DivRound(numLiftsBase, 5).Mul(factor)
+
+	rs.Lifter, rs.Hometown = canonical.lifter, canonical.hometown
+	rs.IWFFirstName, rs.IWFLastName = ToIWFName(canonical.lifter)
+	rs.Hometowns = []string{canonical.hometown}
+	for _, r := range results {
+		rs.Hometowns = appendMissing(rs.Hometowns, r.Hometown)
+	}
+	rs.RecentWeight = results[0].CompetitionWeight
+	rs.Attempts = AnalyzeAttempts(results)
+	rs.PRHistory = markPRs(results)
+	return &rs, nil
+}
+
+func benchmarkSyntheticResults(b *testing.B, query func(o *OurDB, name, hometown string) (*ResultsSummary, error)) {
+	log.SetOutput(ioutil.Discard)
+	o, err := syntheticDB(2000, 25)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer o.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l := i % 2000
+		r, err := query(o, syntheticLifter(l), fmt.Sprintf("Town %d", l%500))
+		if err != nil {
+			b.Fatal(err)
+		}
+		resultsResponse = r
+	}
+}
+
+func BenchmarkSyntheticResultsQuery(b *testing.B) {
+	benchmarkSyntheticResults(b, (*OurDB).QueryResults)
+}
+
+func BenchmarkSyntheticResultsSeparateQueries(b *testing.B) {
+	benchmarkSyntheticResults(b, queryResultsSeparately)
+}
+
+func TestSyntheticResultsSummary(t *testing.T) {
+	o, err := syntheticDB(3, 25)
+	assert.Nil(t, err)
+	defer o.Close()
+
+	rs, err := o.QueryResults(syntheticLifter(2), "Town 2")
+	assert.Nil(t, err)
+	assert.Len(t, rs.Results, 25)
+	assert.Equal(t, "86", rs.BestSN.String())
+	assert.Equal(t, "111", rs.BestCJ.String())
+	assert.Equal(t, "197", rs.BestTotal.String())
+	assert.Equal(t, "66.67", rs.AvgSNMakes.String())
+	assert.Equal(t, "66.67", rs.AvgCJMakes.String())
+	assert.Equal(t, "84", rs.RecentWeight.String())
+
+	best := 0
+	for _, r := range rs.Results {
+		if r.BestResult {
+			best++
+			assert.Equal(t, "197", r.Total.String())
+		}
+	}
+	assert.Equal(t, 1, best)
+
+	// the summary matches the one the old queries built
+	old, err := queryResultsSeparately(o, syntheticLifter(2), "Town 2")
+	assert.Nil(t, err)
+	assert.Equal(t, len(old.Results), len(rs.Results))
+	assert.True(t, old.BestTotal.Equal(rs.BestTotal))
+	assert.True(t, old.BestSinclair.Equal(rs.BestSinclair))
+	assert.True(t, old.AvgSNMakes.Equal(rs.AvgSNMakes), "%v != %v", old.AvgSNMakes, rs.AvgSNMakes)
+	assert.True(t, old.AvgCJMakes.Equal(rs.AvgCJMakes), "%v != %v", old.AvgCJMakes, rs.AvgCJMakes)
+}
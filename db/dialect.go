@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is go-sqlite3 with the Go functions queries call registered
+// on every connection. Postgres gets the same functions from migrations.
+const sqliteDriver = "sqlite3_faststats"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("match_score", matchScore, true)
+		},
+	})
+}
+
+// dialect papers over the SQL differences between the supported databases.
+// Queries are written for sqlite and adjusted where postgres disagrees.
+type dialect int
+
+const (
+	sqliteDialect dialect = iota
+	postgresDialect
+)
+
+func (d dialect) String() string {
+	if d == postgresDialect {
+		return "postgres"
+	}
+	return "sqlite3"
+}
+
+// driver names the database/sql driver to open.
+func (d dialect) driver() string {
+	if d == postgresDialect {
+		return "postgres"
+	}
+	return sqliteDriver
+}
+
+// like returns the case insensitive LIKE operator; sqlite's LIKE already
+// ignores case.
+func (d dialect) like() string {
+	if d == postgresDialect {
+		return "ILIKE"
+	}
+	return "like"
+}
+
+// parseDSN picks the driver from the DSN scheme. postgres:// and
+// postgresql:// URLs use postgres, anything else is a sqlite path or file: URI.
+func parseDSN(dsn string) (dialect, string) {
+	for _, scheme := range []string{"postgres://", "postgresql://"} {
+		if strings.HasPrefix(dsn, scheme) {
+			return postgresDialect, dsn
+		}
+	}
+	return sqliteDialect, strings.TrimPrefix(dsn, "sqlite://")
+}
+
+// SQLitePath returns the file a sqlite DSN opens, or "" for postgres and
+// in memory databases.
+func SQLitePath(dsn string) string {
+	d, source := parseDSN(dsn)
+	if d != sqliteDialect {
+		return ""
+	}
+	source = strings.TrimPrefix(source, "file:")
+	if i := strings.Index(source, "?"); i >= 0 {
+		if strings.Contains(source[i:], "mode=memory") {
+			return ""
+		}
+		source = source[:i]
+	}
+	if source == "" || source == ":memory:" {
+		return ""
+	}
+	return source
+}
+
+// ReadOnly adds _query_only=1 to a sqlite DSN so that its connections refuse
+// writes, an explicit _query_only setting is left alone. Postgres URLs are
+// returned unchanged, serve those with a role that can only read.
+func ReadOnly(dsn string) string {
+	d, _ := parseDSN(dsn)
+	if d != sqliteDialect || strings.Contains(dsn, "_query_only=") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&_query_only=1"
+	}
+	return dsn + "?_query_only=1"
+}
+
+// orderBy sorts text columns bytewise like sqlite's default BINARY collation
+// so both databases paginate identically.
+func (d dialect) orderBy(col string) string {
+	if d == postgresDialect {
+		return col + ` COLLATE "C"`
+	}
+	return col
+}
@@ -0,0 +1,20 @@
+//go:build sqlite_fts5 || fts5
+// +build sqlite_fts5 fts5
+
+package db
+
+// fts5Enabled reports whether go-sqlite3 was built with FTS5, which needs
+// the sqlite_fts5 build tag.
+const fts5Enabled = true
+
+// nameIndexStatements create lifter_names, an FTS5 index of lifters' search
+// names and hometowns, and the triggers keeping it in step with lifters.
+var nameIndexStatements = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS lifter_names USING fts5(search_name, search_hometown)`,
+	`CREATE TRIGGER IF NOT EXISTS lifter_names_insert AFTER INSERT ON lifters BEGIN
+		INSERT INTO lifter_names (rowid, search_name, search_hometown) VALUES (new.id, new.search_name, new.search_hometown);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS lifter_names_delete AFTER DELETE ON lifters BEGIN
+		DELETE FROM lifter_names WHERE rowid = old.id;
+	END`,
+}
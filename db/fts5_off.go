@@ -0,0 +1,11 @@
+//go:build !sqlite_fts5 && !fts5
+// +build !sqlite_fts5,!fts5
+
+package db
+
+// fts5Enabled reports whether go-sqlite3 was built with FTS5, which needs
+// the sqlite_fts5 build tag. Without it searches only use lifter_trigrams.
+const fts5Enabled = false
+
+// nameIndexStatements is empty, lifter_names can't be created without FTS5.
+var nameIndexStatements []string
@@ -0,0 +1,40 @@
+//go:build sqlite_fts5 || fts5
+// +build sqlite_fts5 fts5
+
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameIndex(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+	assert.True(t, db.hasNameIndex())
+
+	count := func(table string) int {
+		var n int
+		assert.Nil(t, db.db.QueryRow(`SELECT COUNT(*) FROM `+table).Scan(&n))
+		return n
+	}
+	assert.Equal(t, count("lifters"), count("lifter_names"), "every lifter is indexed")
+
+	var id int64
+	err = db.db.QueryRow(`SELECT rowid FROM lifter_names WHERE lifter_names MATCH $1`, prefixQuery("fran flo")).Scan(&id)
+	assert.Nil(t, err)
+	var name string
+	assert.Nil(t, db.db.QueryRow(`SELECT lifter FROM lifters WHERE id = $1`, id).Scan(&name))
+	assert.Equal(t, "Francisco Flores", name)
+
+	newcomer := &Result{Date: "2018-06-20", MeetName: "Nationals", Lifter: "José Peña-Núñez", Hometown: "Austin, TX", URL: "http://usaw"}
+	assert.Nil(t, db.UpsertResults([]*Result{newcomer}))
+	assert.Equal(t, count("lifters"), count("lifter_names"), "imports are indexed")
+	r, err := db.QueryNames("pena nunez", "1")
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, r.Lifters) {
+		assert.Equal(t, "José Peña-Núñez", r.Lifters[0].Name)
+	}
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Hometown is a town or club lifters have entered meets from.
+type Hometown struct {
+	Name    string `json:"name"`
+	Lifters int64  `json:"lifters"`
+}
+
+// HometownsResponse is a page of hometowns matching a name.
+type HometownsResponse struct {
+	Hometowns  []Hometown `json:"hometowns"`
+	Name       string     `json:"name"`
+	Total      int64      `json:"total"`
+	Pages      []PageInfo `json:"pages"`
+	Current    int64      `json:"current"`
+	TotalPages int64      `json:"total_pages"`
+}
+
+// HometownLifter is a lifter who has competed from a hometown. Their bests
+// only count meets entered from it.
+type HometownLifter struct {
+	Name      string          `json:"name"`
+	Meets     int64           `json:"meets"`
+	BestTotal decimal.Decimal `json:"best_total"`
+	// FirstYear and LastYear are the years of their first and latest meets
+	FirstYear string `json:"first_year"`
+	LastYear  string `json:"last_year"`
+}
+
+// Attendance is how many meets a hometown's lifters entered in a year.
+type Attendance struct {
+	Year    string `json:"year"`
+	Meets   int64  `json:"meets"`
+	Entries int64  `json:"entries"`
+	Lifters int64  `json:"lifters"`
+}
+
+// HometownResults is everything shown on a hometown's page.
+type HometownResults struct {
+	Name    string           `json:"name"`
+	Lifters []HometownLifter `json:"lifters"`
+	// Attendance is by year, oldest first
+	Attendance []Attendance `json:"attendance"`
+	// PeakEntries is the most entries in any year, for scaling charts
+	PeakEntries int64 `json:"-"`
+}
+
+// QueryHometowns returns a page of hometowns whose names match name, the
+// ones with the most lifters first. An empty name lists every hometown.
+func (o *OurDB) QueryHometowns(name, offset string) (*HometownsResponse, error) {
+	logQuery("hometowns: %v, offset: %v\n", name, offset)
+	w := &where{}
+	w.add("hometown <> ''")
+	if name != "" {
+		w.add("hometown "+o.dialect.like()+" ?", "%"+strings.Replace(name, " ", "%", -1)+"%")
+	}
+
+	resp := &HometownsResponse{Name: name}
+	err := o.db.QueryRow(`SELECT COUNT(DISTINCT hometown) FROM results`+w.String(), w.args...).Scan(&resp.Total)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Total == 0 {
+		return resp, nil
+	}
+	onum, numPages, pages := pageRange(resp.Total, offset)
+
+	q := `SELECT hometown, COUNT(DISTINCT lifter) AS lifters FROM results` + w.String() +
+		` GROUP BY hometown ORDER BY lifters DESC, ` + o.dialect.orderBy("hometown") + ` ASC LIMIT ` + w.next(pageLimit) + ` OFFSET ` + w.next((onum-1)*pageLimit)
+	rows, err := o.db.Query(q, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp.Hometowns = make([]Hometown, 0, getPageSize(onum, resp.Total, pageLimit))
+	for rows.Next() {
+		var h Hometown
+		if err := rows.Scan(&h.Name, &h.Lifters); err != nil {
+			return nil, err
+		}
+		resp.Hometowns = append(resp.Hometowns, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	resp.Current, resp.Pages, resp.TotalPages = onum, pages, numPages
+	return resp, nil
+}
+
+// QueryHometown returns every lifter who has competed from a hometown, best
+// total first, and the hometown's meet attendance by year.
+func (o *OurDB) QueryHometown(name string) (*HometownResults, error) {
+	logQuery("hometown: %v\n", name)
+	resp := &HometownResults{Name: name}
+
+	rows, err := o.db.Query(`SELECT lifter, COUNT(*), MAX(total), MIN(date), MAX(date) FROM results WHERE hometown = $1 GROUP BY lifter ORDER BY MAX(total) DESC, `+o.dialect.orderBy("lifter")+` ASC`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l HometownLifter
+		var first, last string
+		if err := rows.Scan(&l.Name, &l.Meets, &l.BestTotal, &first, &last); err != nil {
+			return nil, err
+		}
+		l.FirstYear, l.LastYear = year(first), year(last)
+		resp.Lifters = append(resp.Lifters, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(resp.Lifters) == 0 {
+		return resp, nil
+	}
+
+	rows, err = o.db.Query(`SELECT date, meet_name, lifter FROM results WHERE hometown = $1 ORDER BY date`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type meetKey struct{ name, date string }
+	var cur *Attendance
+	var meets map[meetKey]bool
+	var lifters map[string]bool
+	for rows.Next() {
+		var date, meet, lifter string
+		if err := rows.Scan(&date, &meet, &lifter); err != nil {
+			return nil, err
+		}
+		if cur == nil || cur.Year != year(date) {
+			resp.Attendance = append(resp.Attendance, Attendance{Year: year(date)})
+			cur = &resp.Attendance[len(resp.Attendance)-1]
+			meets, lifters = map[meetKey]bool{}, map[string]bool{}
+		}
+		cur.Entries++
+		if k := (meetKey{meet, date}); !meets[k] {
+			meets[k] = true
+			cur.Meets++
+		}
+		if !lifters[lifter] {
+			lifters[lifter] = true
+			cur.Lifters++
+		}
+		if cur.Entries > resp.PeakEntries {
+			resp.PeakEntries = cur.Entries
+		}
+	}
+	return resp, rows.Err()
+}
+
+// year is the year of a YYYY-MM-DD date.
+func year(date string) string {
+	if len(date) < 4 {
+		return date
+	}
+	return date[:4]
+}
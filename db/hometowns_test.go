@@ -0,0 +1,65 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryHometowns(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	r, err := db.QueryHometowns("", "")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), r.Total)
+	assert.Equal(t, int64(1), r.TotalPages)
+	assert.Equal(t, Hometown{Name: "Springfield, IL", Lifters: 28}, r.Hometowns[0], "most lifters first")
+	assert.Equal(t, Hometown{Name: "Springfield, MO", Lifters: 28}, r.Hometowns[1])
+	assert.Equal(t, Hometown{Name: "Austin, TX", Lifters: 1}, r.Hometowns[2], "then by name")
+
+	r, err = db.QueryHometowns("springfield", "")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), r.Total)
+
+	r, err = db.QueryHometowns("nowhere", "")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), r.Total)
+	assert.Empty(t, r.Hometowns)
+}
+
+func TestQueryHometown(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	r, err := db.QueryHometown("Austin, TX")
+	assert.Nil(t, err)
+	assert.Len(t, r.Lifters, 1)
+	l := r.Lifters[0]
+	assert.Equal(t, "Chris Wolfe", l.Name)
+	assert.Equal(t, int64(3), l.Meets)
+	assert.Equal(t, "192", l.BestTotal.String())
+	assert.Equal(t, "2017", l.FirstYear)
+	assert.Equal(t, "2018", l.LastYear)
+	assert.Equal(t, []Attendance{
+		{Year: "2017", Meets: 2, Entries: 2, Lifters: 1},
+		{Year: "2018", Meets: 1, Entries: 1, Lifters: 1},
+	}, r.Attendance)
+	assert.Equal(t, int64(2), r.PeakEntries)
+
+	r, err = db.QueryHometown("Springfield, IL")
+	assert.Nil(t, err)
+	assert.Len(t, r.Lifters, 28)
+	assert.Equal(t, "Stephan Clark", r.Lifters[0].Name, "best total first")
+	assert.Equal(t, []Attendance{
+		{Year: "2018", Meets: 1, Entries: 14, Lifters: 14},
+		{Year: "2019", Meets: 1, Entries: 14, Lifters: 14},
+	}, r.Attendance)
+
+	r, err = db.QueryHometown("Nowhere")
+	assert.Nil(t, err)
+	assert.Empty(t, r.Lifters)
+	assert.Empty(t, r.Attendance)
+}
@@ -0,0 +1,323 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// maxWeightChange is the largest relative bodyweight change between one
+// identity's last meet and another's first meet for them to be proposed as
+// the same athlete.
+const maxWeightChange = 0.15
+
+// identity is a (lifter, hometown) pair as stored in results.
+type identity struct {
+	lifter, hometown string
+}
+
+func (i identity) asLifter() Lifter {
+	return Lifter{Name: i.lifter, Hometown: i.hometown}
+}
+
+// Alias records that results under one lifter and hometown belong to the
+// athlete known by Canonical.
+type Alias struct {
+	Alias     Lifter `json:"alias"`
+	Canonical Lifter `json:"canonical"`
+}
+
+// MergeProposal is a pair of identities that look like the same athlete.
+// Nothing is merged until MergeLifters is called.
+type MergeProposal struct {
+	Alias     Lifter
+	Canonical Lifter
+	Reason    string
+}
+
+// canonical returns the athlete name and hometown is merged into, or name
+// and hometown themselves when they aren't an alias.
+func (o *OurDB) canonical(name, hometown string) (identity, error) {
+	c := identity{name, hometown}
+	err := o.db.QueryRow(`SELECT canonical_lifter, canonical_hometown FROM lifter_aliases WHERE lifter = $1 AND hometown = $2`, name, hometown).Scan(&c.lifter, &c.hometown)
+	if err == sql.ErrNoRows {
+		return identity{name, hometown}, nil
+	}
+	return c, err
+}
+
+// aliasMap returns every alias keyed to its canonical identity.
+func (o *OurDB) aliasMap() (map[identity]identity, error) {
+	rows, err := o.db.Query(`SELECT lifter, hometown, canonical_lifter, canonical_hometown FROM lifter_aliases`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := map[identity]identity{}
+	for rows.Next() {
+		var a, c identity
+		if err := rows.Scan(&a.lifter, &a.hometown, &c.lifter, &c.hometown); err != nil {
+			return nil, err
+		}
+		aliases[a] = c
+	}
+	return aliases, rows.Err()
+}
+
+// listHometowns sets Hometowns on search matches that are merged athletes,
+// which search already names by their canonical identity. Alias hometowns
+// follow the canonical one in order, so every page lists them alike.
+func (o *OurDB) listHometowns(lifters []Lifter) ([]Lifter, error) {
+	aliases, err := o.Aliases()
+	if err != nil || len(aliases) == 0 {
+		return lifters, err
+	}
+	hometowns := map[identity][]string{}
+	for _, a := range aliases {
+		c := identity{a.Canonical.Name, a.Canonical.Hometown}
+		if len(hometowns[c]) == 0 {
+			hometowns[c] = []string{c.hometown}
+		}
+		hometowns[c] = appendMissing(hometowns[c], a.Alias.Hometown)
+	}
+	for i, l := range lifters {
+		lifters[i].Hometowns = hometowns[identity{l.Name, l.Hometown}]
+	}
+	return lifters, nil
+}
+
+func appendMissing(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// Aliases lists every confirmed merge.
+func (o *OurDB) Aliases() ([]Alias, error) {
+	rows, err := o.db.Query(`SELECT lifter, hometown, canonical_lifter, canonical_hometown FROM lifter_aliases ORDER BY canonical_lifter, canonical_hometown, lifter, hometown`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found []Alias
+	for rows.Next() {
+		var a Alias
+		if err := rows.Scan(&a.Alias.Name, &a.Alias.Hometown, &a.Canonical.Name, &a.Canonical.Hometown); err != nil {
+			return nil, err
+		}
+		found = append(found, a)
+	}
+	return found, rows.Err()
+}
+
+// MergeLifters records that alias is the same athlete as canonical. Anything
+// already merged into alias moves to canonical, so merges never chain.
+func (o *OurDB) MergeLifters(alias, canonical Lifter) error {
+	c, err := o.canonical(canonical.Name, canonical.Hometown)
+	if err != nil {
+		return err
+	}
+	a := identity{alias.Name, alias.Hometown}
+	if a == c {
+		return fmt.Errorf("%v from %v can't be merged into itself", alias.Name, alias.Hometown)
+	}
+	for _, i := range []identity{a, c} {
+		var ct int64
+		if err := o.db.QueryRow(`SELECT COUNT(*) FROM results WHERE lifter = $1 AND hometown = $2`, i.lifter, i.hometown).Scan(&ct); err != nil {
+			return err
+		}
+		if ct == 0 {
+			return fmt.Errorf("no results found for %v from %v", i.lifter, i.hometown)
+		}
+	}
+
+	tx, err := o.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmts := []struct {
+		q    string
+		args []interface{}
+	}{
+		{`UPDATE lifter_aliases SET canonical_lifter = $1, canonical_hometown = $2 WHERE canonical_lifter = $3 AND canonical_hometown = $4`, []interface{}{c.lifter, c.hometown, a.lifter, a.hometown}},
+		{`DELETE FROM lifter_aliases WHERE lifter = $1 AND hometown = $2`, []interface{}{a.lifter, a.hometown}},
+		{`INSERT INTO lifter_aliases (lifter, hometown, canonical_lifter, canonical_hometown) VALUES ($1, $2, $3, $4)`, []interface{}{a.lifter, a.hometown, c.lifter, c.hometown}},
+	}
+	for _, s := range stmts {
+		if _, err := tx.Exec(s.q, s.args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SplitLifter undoes a merge so alias is its own athlete again.
+func (o *OurDB) SplitLifter(alias Lifter) error {
+	res, err := o.db.Exec(`DELETE FROM lifter_aliases WHERE lifter = $1 AND hometown = $2`, alias.Name, alias.Hometown)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%v from %v isn't merged", alias.Name, alias.Hometown)
+	}
+	return nil
+}
+
+// span is when an identity competed and what it weighed at either end.
+type span struct {
+	first, last             string
+	firstWeight, lastWeight float64
+}
+
+func (o *OurDB) identitySpan(i identity) (span, error) {
+	var s span
+	rows, err := o.db.Query(`SELECT date, competition_weight FROM results WHERE lifter = $1 AND hometown = $2 ORDER BY date`, i.lifter, i.hometown)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var date string
+		var weight float64
+		if err := rows.Scan(&date, &weight); err != nil {
+			return s, err
+		}
+		if s.first == "" {
+			s.first, s.firstWeight = date, weight
+		}
+		s.last, s.lastWeight = date, weight
+	}
+	return s, rows.Err()
+}
+
+// plausibleWeight reports whether an athlete could weigh to at their first
+// meet after weighing from at their last one. Missing weights don't count
+// against a merge.
+func plausibleWeight(from, to float64) bool {
+	if from <= 0 || to <= 0 {
+		return true
+	}
+	return math.Abs(to-from)/from <= maxWeightChange
+}
+
+// ProposeMerges suggests identities that are probably the same athlete:
+// the same name in different hometowns, or names one edit apart in the same
+// hometown, that never competed in the same period and whose bodyweight
+// carries on plausibly from one to the other. The later identity is proposed
+// as canonical. Identities already merged into another are left alone.
+func (o *OurDB) ProposeMerges() ([]MergeProposal, error) {
+	aliases, err := o.aliasMap()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := o.db.Query(`SELECT lifter, hometown, search_name FROM lifters`)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string][]identity{}
+	byHometown := map[string][]identity{}
+	searchNames := map[identity]string{}
+	for rows.Next() {
+		var i identity
+		var searchName string
+		if err := rows.Scan(&i.lifter, &i.hometown, &searchName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if _, ok := aliases[i]; ok {
+			continue
+		}
+		byName[searchName] = append(byName[searchName], i)
+		home := normalizeName(i.hometown)
+		byHometown[home] = append(byHometown[home], i)
+		searchNames[i] = searchName
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	type pair struct{ a, b identity }
+	var pairs []pair
+	reasons := map[pair]string{}
+	for _, group := range byName {
+		for x := 0; x < len(group); x++ {
+			for y := x + 1; y < len(group); y++ {
+				p := pair{group[x], group[y]}
+				pairs = append(pairs, p)
+				reasons[p] = "same name"
+			}
+		}
+	}
+	for _, group := range byHometown {
+		for x := 0; x < len(group); x++ {
+			for y := x + 1; y < len(group); y++ {
+				if levenshtein(searchNames[group[x]], searchNames[group[y]]) == 1 {
+					p := pair{group[x], group[y]}
+					pairs = append(pairs, p)
+					reasons[p] = "similar name"
+				}
+			}
+		}
+	}
+
+	spans := map[identity]span{}
+	spanOf := func(i identity) (span, error) {
+		if s, ok := spans[i]; ok {
+			return s, nil
+		}
+		s, err := o.identitySpan(i)
+		spans[i] = s
+		return s, err
+	}
+
+	var proposals []MergeProposal
+	for _, p := range pairs {
+		earlier, later := p.a, p.b
+		es, err := spanOf(earlier)
+		if err != nil {
+			return nil, err
+		}
+		ls, err := spanOf(later)
+		if err != nil {
+			return nil, err
+		}
+		if ls.first < es.first {
+			earlier, later, es, ls = later, earlier, ls, es
+		}
+		if es.first == "" || es.last >= ls.first {
+			continue
+		}
+		if !plausibleWeight(es.lastWeight, ls.firstWeight) {
+			continue
+		}
+		proposals = append(proposals, MergeProposal{
+			Alias:     earlier.asLifter(),
+			Canonical: later.asLifter(),
+			Reason:    fmt.Sprintf("%v, competed until %v at %v kg then from %v at %v kg", reasons[p], es.last, es.lastWeight, ls.first, ls.firstWeight),
+		})
+	}
+
+	sort.Slice(proposals, func(i, j int) bool {
+		a, b := proposals[i], proposals[j]
+		if a.Canonical.Name != b.Canonical.Name {
+			return a.Canonical.Name < b.Canonical.Name
+		}
+		if a.Canonical.Hometown != b.Canonical.Hometown {
+			return a.Canonical.Hometown < b.Canonical.Hometown
+		}
+		return a.Alias.Hometown < b.Alias.Hometown
+	})
+	return proposals, nil
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlausibleWeight(t *testing.T) {
+	assert.True(t, plausibleWeight(92.3, 95.1))
+	assert.True(t, plausibleWeight(0, 95.1), "missing weights don't block a merge")
+	assert.False(t, plausibleWeight(62, 105))
+}
+
+func TestMergeLifters(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	reno := Lifter{Name: "Kyle Brown", Hometown: "Reno, NV"}
+	portland := Lifter{Name: "Kyle Brown", Hometown: "Portland, OR"}
+
+	proposals, err := db.ProposeMerges()
+	assert.Nil(t, err)
+	found := false
+	for _, p := range proposals {
+		if assert.ObjectsAreEqual(p.Alias, reno) && assert.ObjectsAreEqual(p.Canonical, portland) {
+			found = true
+		}
+	}
+	assert.True(t, found, "Kyle Brown moved from Reno to Portland: %v", proposals)
+
+	assert.NotNil(t, db.MergeLifters(reno, reno))
+	assert.NotNil(t, db.MergeLifters(Lifter{Name: "Nobody", Hometown: "Nowhere"}, portland))
+	assert.Nil(t, db.MergeLifters(reno, portland))
+
+	rs, err := db.QueryResults("Kyle Brown", "Reno, NV")
+	assert.Nil(t, err)
+	assert.Equal(t, "Portland, OR", rs.Hometown, "aliases resolve to the canonical lifter")
+	assert.Equal(t, []string{"Portland, OR", "Reno, NV"}, rs.Hometowns)
+	assert.Len(t, rs.Results, 2)
+
+	rs, err = db.QueryResults("Kyle Brown", "Portland, OR")
+	assert.Nil(t, err)
+	assert.Len(t, rs.Results, 2, "the canonical lifter includes merged results")
+
+	names, err := db.QueryNames("kyle brown", "")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), names.Total)
+	assert.Equal(t, []string{"Portland, OR", "Reno, NV"}, names.Lifters[0].Hometowns)
+
+	proposals, err = db.ProposeMerges()
+	assert.Nil(t, err)
+	for _, p := range proposals {
+		assert.NotEqual(t, reno, p.Alias, "merged lifters aren't proposed again")
+	}
+
+	aliases, err := db.Aliases()
+	assert.Nil(t, err)
+	assert.Len(t, aliases, 1)
+
+	assert.Nil(t, db.SplitLifter(reno))
+	assert.NotNil(t, db.SplitLifter(reno))
+	rs, err = db.QueryResults("Kyle Brown", "Reno, NV")
+	assert.Nil(t, err)
+	assert.Len(t, rs.Results, 1)
+}
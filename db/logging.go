@@ -0,0 +1,25 @@
+package db
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+var queryLogging int32 = 1
+
+// SetQueryLogging turns the per query trace on or off, it is on by default.
+// Errors are always returned rather than logged.
+func SetQueryLogging(on bool) {
+	v := int32(0)
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&queryLogging, v)
+}
+
+// logQuery traces a query to the standard logger.
+func logQuery(format string, v ...interface{}) {
+	if atomic.LoadInt32(&queryLogging) == 1 {
+		log.Printf(format, v...)
+	}
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"gitlab.com/derwolfe/faststats/scoring"
+)
+
+// Meet is a single competition, identified by its name and date.
+type Meet struct {
+	Name    string `json:"name"`
+	Date    string `json:"date"`
+	URL     string `json:"url"`
+	Entries int64  `json:"entries"`
+}
+
+// MeetFilter narrows the meets returned by QueryMeets. Empty fields are ignored.
+type MeetFilter struct {
+	Name string `json:"name"`
+	// From and To are inclusive YYYY-MM-DD dates
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type MeetsResponse struct {
+	Meets      []Meet     `json:"meets"`
+	Filter     MeetFilter `json:"filter"`
+	Total      int64      `json:"total"`
+	Pages      []PageInfo `json:"pages"`
+	Current    int64      `json:"current"`
+	TotalPages int64      `json:"total_pages"`
+}
+
+// MeetEntry is a lifter's result at a meet along with their placing in the
+// weight class. Place is 0 for lifters who didn't post a total.
+type MeetEntry struct {
+	*Result
+	Place int `json:"place"`
+}
+
+type WeightClassResults struct {
+	Weightclass string       `json:"weight_class"`
+	Entries     []*MeetEntry `json:"entries"`
+}
+
+type MeetResults struct {
+	Name          string                `json:"name"`
+	Date          string                `json:"date"`
+	URL           string                `json:"url"`
+	Entries       int64                 `json:"entries"`
+	WeightClasses []*WeightClassResults `json:"weight_classes"`
+}
+
+func (f MeetFilter) where(d dialect) *where {
+	w := &where{}
+	if f.Name != "" {
+		w.add("meet_name "+d.like()+" ?", "%"+strings.Replace(f.Name, " ", "%", -1)+"%")
+	}
+	if f.From != "" {
+		w.add("date >= ?", f.From)
+	}
+	if f.To != "" {
+		w.add("date <= ?", f.To)
+	}
+	return w
+}
+
+// QueryMeets returns a page of meets matching the filter, most recent first.
+func (o *OurDB) QueryMeets(f MeetFilter, offset string) (*MeetsResponse, error) {
+	logQuery("meets: %+v, offset: %v\n", f, offset)
+	w := f.where(o.dialect)
+
+	var total int64
+	err := o.db.QueryRow(`SELECT COUNT(*) FROM (SELECT 1 FROM results`+w.String()+` GROUP BY meet_name, date) AS meets`, w.args...).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		return &MeetsResponse{Filter: f}, nil
+	}
+
+	onum, numPages, pages := pageRange(total, offset)
+
+	q := `SELECT meet_name, date, MAX(url), COUNT(*) FROM results` + w.String() +
+		` GROUP BY meet_name, date ORDER BY date DESC, ` + o.dialect.orderBy("meet_name") + ` ASC LIMIT ` + w.next(pageLimit) + ` OFFSET ` + w.next((onum-1)*pageLimit)
+	rows, err := o.db.Query(q, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	meets := make([]Meet, 0, getPageSize(onum, total, pageLimit))
+	for rows.Next() {
+		m := Meet{}
+		if err := rows.Scan(&m.Name, &m.Date, &m.URL, &m.Entries); err != nil {
+			return nil, err
+		}
+		meets = append(meets, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &MeetsResponse{
+		Meets:      meets,
+		Filter:     f,
+		Total:      total,
+		Pages:      pages,
+		Current:    onum,
+		TotalPages: numPages,
+	}, nil
+}
+
+// QueryMeet returns every entry in a meet grouped by weight class and placed
+// within each class.
+func (o *OurDB) QueryMeet(name, date string) (*MeetResults, error) {
+	logQuery("meet: %v, date: %v\n", name, date)
+	rows, err := o.db.Query(`SELECT date, meet_name, lifter, weight_class, competition_weight, hometown, cj1, cj2, cj3, sn1, sn2, sn3, total, best_snatch, best_cleanjerk, url FROM results WHERE meet_name = $1 and date = $2`, name, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mr := &MeetResults{Name: name, Date: date}
+	classes := map[string]*WeightClassResults{}
+	for rows.Next() {
+		r := &Result{}
+		err = rows.Scan(&r.Date, &r.MeetName, &r.Lifter, &r.Weightclass, &r.CompetitionWeight, &r.Hometown, &r.CJ1, &r.CJ2, &r.CJ3, &r.SN1, &r.SN2, &r.SN3, &r.Total, &r.BestSN, &r.BestCJ, &r.URL)
+		if err != nil {
+			return nil, err
+		}
+		r.missesToMakes()
+		r.Sinclair = scoring.Sinclair(r.Total, r.CompetitionWeight, r.Date, r.Weightclass)
+
+		wc, ok := classes[r.Weightclass]
+		if !ok {
+			wc = &WeightClassResults{Weightclass: r.Weightclass}
+			classes[r.Weightclass] = wc
+			mr.WeightClasses = append(mr.WeightClasses, wc)
+		}
+		wc.Entries = append(wc.Entries, &MeetEntry{Result: r})
+		mr.URL = r.URL
+		mr.Entries++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(mr.WeightClasses, func(i, j int) bool {
+		return weightClassLess(mr.WeightClasses[i].Weightclass, mr.WeightClasses[j].Weightclass)
+	})
+	for _, wc := range mr.WeightClasses {
+		placeEntries(wc.Entries)
+	}
+	return mr, nil
+}
+
+// placeEntries orders entries by total, breaking ties with the lighter
+// bodyweight, and assigns placings. Lifters without a total aren't placed.
+func placeEntries(entries []*MeetEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !a.Total.Equal(b.Total) {
+			return a.Total.GreaterThan(b.Total)
+		}
+		return a.CompetitionWeight.LessThan(b.CompetitionWeight)
+	})
+	for i, e := range entries {
+		if e.Total.Sign() <= 0 {
+			e.Place = 0
+			continue
+		}
+		e.Place = i + 1
+		// identical total and bodyweight share a placing
+		if i > 0 {
+			prev := entries[i-1]
+			if prev.Place > 0 && prev.Total.Equal(e.Total) && prev.CompetitionWeight.Equal(e.CompetitionWeight) {
+				e.Place = prev.Place
+			}
+		}
+	}
+}
+
+var (
+	// classKgReg finds the figure marked kg in a weight class, classLimitReg
+	// any figure for classes written without a unit
+	classKgReg    = regexp.MustCompile(`(?i)(\+)?\s*(\d+(\.\d+)?)\s*(\+)?\s*kg`)
+	classLimitReg = regexp.MustCompile(`(\+)?\s*(\d+(\.\d+)?)\s*(\+)?`)
+)
+
+// weightClassLimit returns the numeric limit of a weight class. Unlimited
+// classes such as +105 sort after every limited class. Age groups such as
+// "13-15 Age Group 55kg" come before the limit, so the kg figure is used and
+// failing that the last figure in the class.
+func weightClassLimit(weightClass string) decimal.Decimal {
+	m := classKgReg.FindStringSubmatch(weightClass)
+	if m == nil {
+		all := classLimitReg.FindAllStringSubmatch(weightClass, -1)
+		if len(all) == 0 {
+			return decimal.New(math.MaxInt32, 0)
+		}
+		m = all[len(all)-1]
+	}
+	limit, err := strconv.ParseFloat(m[2], 64)
+	if err != nil {
+		return decimal.New(math.MaxInt32, 0)
+	}
+	d := decimal.NewFromFloat(limit)
+	if m[1] != "" || m[4] != "" {
+		d = d.Add(decimal.New(1000, 0))
+	}
+	return d
+}
+
+// weightClassLess orders weight classes women first, then by limit.
+func weightClassLess(a, b string) bool {
+	ga, gb := scoring.GenderFromWeightClass(a), scoring.GenderFromWeightClass(b)
+	if ga != gb {
+		return ga > gb
+	}
+	la, lb := weightClassLimit(a), weightClassLimit(b)
+	if !la.Equal(lb) {
+		return la.LessThan(lb)
+	}
+	return a < b
+}
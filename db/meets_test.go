@@ -0,0 +1,109 @@
+package db
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func entry(lifter, total, bodyweight string) *MeetEntry {
+	return &MeetEntry{Result: &Result{
+		Lifter:            lifter,
+		Total:             decimal.RequireFromString(total),
+		CompetitionWeight: decimal.RequireFromString(bodyweight),
+	}}
+}
+
+func TestPlaceEntries(t *testing.T) {
+	entries := []*MeetEntry{
+		entry("bombed", "0", "60"),
+		entry("second", "180", "62.5"),
+		entry("first", "180", "61.2"),
+		entry("third", "170", "58"),
+		entry("tied third", "170", "58"),
+		entry("fifth", "150", "55"),
+	}
+	placeEntries(entries)
+
+	var lifters []string
+	var places []int
+	for _, e := range entries {
+		lifters = append(lifters, e.Lifter)
+		places = append(places, e.Place)
+	}
+	assert.Equal(t, []string{"first", "second", "third", "tied third", "fifth", "bombed"}, lifters)
+	assert.Equal(t, []int{1, 2, 3, 3, 5, 0}, places)
+}
+
+func TestWeightClassLess(t *testing.T) {
+	classes := []string{"Men's +105Kg", "Men's 69Kg", "Women's 58Kg", "Men's 105Kg", "Women's +75Kg", "Women's 48Kg"}
+	sort.SliceStable(classes, func(i, j int) bool { return weightClassLess(classes[i], classes[j]) })
+	assert.Equal(t, []string{"Women's 48Kg", "Women's 58Kg", "Women's +75Kg", "Men's 69Kg", "Men's 105Kg", "Men's +105Kg"}, classes)
+}
+
+func TestWeightClassLimit(t *testing.T) {
+	cases := []struct {
+		class, limit string
+	}{
+		{"Men's 85Kg", "85"},
+		{"Women's +90 Kg", "1090"},
+		{"Men's 105+", "1105"},
+		{"13-15 Age Group 55kg", "55"},
+		{"Women's 16-17 Age Group 58 Kg", "58"},
+		{"Masters (35-39) Men's 94", "94"},
+		{"Open", "2147483647"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.class, func(t *testing.T) {
+			assert.Equal(t, tt.limit, weightClassLimit(tt.class).String())
+		})
+	}
+
+	classes := []string{"13-15 Age Group 62kg", "16-17 Age Group 56kg", "13-15 Age Group 50kg"}
+	sort.SliceStable(classes, func(i, j int) bool { return weightClassLess(classes[i], classes[j]) })
+	assert.Equal(t, []string{"13-15 Age Group 50kg", "16-17 Age Group 56kg", "13-15 Age Group 62kg"}, classes)
+}
+
+func TestWhere(t *testing.T) {
+	w := (MeetFilter{Name: "nationals", From: "2018-01-01"}).where(sqliteDialect)
+	limit := w.next(50)
+	assert.Equal(t, " WHERE meet_name like $1 AND date >= $2", w.String())
+	assert.Equal(t, "$3", limit)
+	assert.Equal(t, []interface{}{"%nationals%", "2018-01-01", 50}, w.args)
+
+	assert.Equal(t, "", (&where{}).String())
+}
+
+func TestQueryMeets(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	r, err := db.QueryMeets(MeetFilter{From: "2018-01-01", To: "2018-12-31"}, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), r.Total)
+	assert.Equal(t, "American Open Finals", r.Meets[0].Name, "meets are most recent first")
+
+	r, err = db.QueryMeets(MeetFilter{Name: "american open"}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), r.Total)
+}
+
+func TestQueryMeet(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	m, err := db.QueryMeet("American Open Finals", "2018-12-01")
+	assert.Nil(t, err)
+	assert.True(t, m.Entries > 0)
+	for _, wc := range m.WeightClasses {
+		for _, e := range wc.Entries {
+			if e.Lifter == "Kyle Brown" {
+				assert.Equal(t, 0, e.Place, "a bomb out isn't placed")
+			}
+		}
+	}
+}
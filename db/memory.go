@@ -0,0 +1,68 @@
+package db
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+var memoryDBs int64
+
+// NewMemoryDB returns a migrated database held entirely in memory and loaded
+// with results. It is meant for tests and demos, not production data.
+func NewMemoryDB(results []*Result) (*OurDB, error) {
+	// every connection in the pool has to see the same database, a plain
+	// :memory: database is private to a single connection
+	n := atomic.AddInt64(&memoryDBs, 1)
+	o, err := OpenDB(fmt.Sprintf("file:faststats-memory-%d?mode=memory&cache=shared", n))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := o.Migrate(); err != nil {
+		o.Close()
+		return nil, err
+	}
+	if err := o.UpsertResults(results); err != nil {
+		o.Close()
+		return nil, err
+	}
+	return o, nil
+}
+
+// ReadFixtures decodes results written one JSON object per line, using the
+// same field names as the JSON API.
+func ReadFixtures(r io.Reader) ([]*Result, error) {
+	var results []*Result
+	s := bufio.NewScanner(r)
+	line := 0
+	for s.Scan() {
+		line++
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		res := &Result{}
+		if err := json.Unmarshal(s.Bytes(), res); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		results = append(results, res)
+	}
+	return results, s.Err()
+}
+
+// LoadFixtures returns an in memory database loaded from a fixtures file.
+func LoadFixtures(path string) (*OurDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	results, err := ReadFixtures(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return NewMemoryDB(results)
+}
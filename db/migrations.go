@@ -0,0 +1,332 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migration is one ordered step in building the schema. Statements run in a
+// single transaction along with recording the new version.
+type migration struct {
+	version    int
+	name       string
+	statements []string
+	// postgres replaces statements when they can't be shared
+	postgres []string
+	// after runs in the same transaction once the statements succeed
+	after func(tx *sql.Tx) error
+}
+
+func (m migration) statementsFor(d dialect) []string {
+	if d == postgresDialect && m.postgres != nil {
+		return m.postgres
+	}
+	return m.statements
+}
+
+// migrations must only ever be appended to. Earlier steps use IF NOT EXISTS
+// so databases built by hand before versioning can be brought up to date.
+var migrations = []migration{
+	{1, "create results", []string{
+		`CREATE TABLE IF NOT EXISTS results (
+			date TEXT NOT NULL,
+			meet_name TEXT NOT NULL,
+			lifter TEXT NOT NULL,
+			weight_class TEXT NOT NULL DEFAULT '',
+			competition_weight NUMERIC NOT NULL DEFAULT 0,
+			hometown TEXT NOT NULL DEFAULT '',
+			cj1 NUMERIC NOT NULL DEFAULT 0,
+			cj2 NUMERIC NOT NULL DEFAULT 0,
+			cj3 NUMERIC NOT NULL DEFAULT 0,
+			sn1 NUMERIC NOT NULL DEFAULT 0,
+			sn2 NUMERIC NOT NULL DEFAULT 0,
+			sn3 NUMERIC NOT NULL DEFAULT 0,
+			total NUMERIC NOT NULL DEFAULT 0,
+			best_snatch NUMERIC NOT NULL DEFAULT 0,
+			best_cleanjerk NUMERIC NOT NULL DEFAULT 0,
+			url TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_lifter_hometown ON results(lifter, hometown)`,
+	}, nil, nil},
+	{2, "unique results and meet indexes", []string{
+		`CREATE INDEX IF NOT EXISTS idx_meet_date ON results(meet_name, date)`,
+		`CREATE INDEX IF NOT EXISTS idx_date ON results(date)`,
+	}, nil, uniqueResults},
+	{3, "lifter search index", []string{
+		`CREATE TABLE IF NOT EXISTS lifters (
+			id INTEGER NOT NULL PRIMARY KEY,
+			lifter TEXT NOT NULL,
+			hometown TEXT NOT NULL,
+			search_name TEXT NOT NULL,
+			meets INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_lifters_lifter ON lifters(lifter)`,
+		`CREATE TABLE IF NOT EXISTS lifter_trigrams (
+			trigram TEXT NOT NULL,
+			lifter_id INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_lifter_trigrams ON lifter_trigrams(trigram, lifter_id)`,
+		// filled by migration 8 once lifters has every column
+	}, nil, nil},
+	{4, "lifter aliases", []string{
+		`CREATE TABLE IF NOT EXISTS lifter_aliases (
+			lifter TEXT NOT NULL,
+			hometown TEXT NOT NULL,
+			canonical_lifter TEXT NOT NULL,
+			canonical_hometown TEXT NOT NULL,
+			PRIMARY KEY (lifter, hometown)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_lifter_aliases_canonical ON lifter_aliases(canonical_lifter, canonical_hometown)`,
+	}, nil, nil},
+	{5, "lifter name prefix index", []string{
+		`CREATE TABLE IF NOT EXISTS lifter_words (
+			word TEXT NOT NULL,
+			lifter_id INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_lifter_words ON lifter_words(word, lifter_id)`,
+	}, []string{
+		`CREATE TABLE IF NOT EXISTS lifter_words (
+			word TEXT NOT NULL,
+			lifter_id INTEGER NOT NULL
+		)`,
+		// prefix ranges compare bytewise, see dialect.orderBy
+		`CREATE INDEX IF NOT EXISTS idx_lifter_words ON lifter_words(word COLLATE "C", lifter_id)`,
+	}, rebuildPrefixIndex},
+	{6, "stored sinclair scores", []string{
+		`ALTER TABLE results ADD COLUMN sinclair NUMERIC NOT NULL DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_sinclair ON results(sinclair)`,
+	}, nil, fillSinclair},
+	{7, "results without a url keyed by meet", []string{
+		// a url names the meet, without one every meet a lifter attended
+		// would collapse into a single row
+		`DROP INDEX IF EXISTS idx_url_lifter_hometown`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_url_lifter_hometown ON results(url, lifter, hometown) WHERE url <> ''`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_unlinked_meet_lifter_hometown ON results(meet_name, date, lifter, hometown) WHERE url = ''`,
+	}, nil, nil},
+	{8, "lifter search scored in sql", append([]string{
+		`ALTER TABLE lifters ADD COLUMN search_hometown TEXT NOT NULL DEFAULT ''`,
+	}, nameIndexStatements...), []string{
+		`ALTER TABLE lifters ADD COLUMN search_hometown TEXT NOT NULL DEFAULT ''`,
+		// ports of levenshtein, wordSimilarity and matchScore, sqlite calls
+		// the Go functions, see sqliteDriver
+		`CREATE OR REPLACE FUNCTION match_distance(a text, b text) RETURNS integer AS $$
+		DECLARE
+			prev integer[] := array(SELECT generate_series(0, char_length(b)));
+			cur integer[];
+			cost integer;
+		BEGIN
+			FOR i IN 1..char_length(a) LOOP
+				cur := array[i];
+				FOR j IN 1..char_length(b) LOOP
+					cost := CASE WHEN substr(a, i, 1) = substr(b, j, 1) THEN 0 ELSE 1 END;
+					cur := cur || least(prev[j + 1] + 1, cur[j] + 1, prev[j] + cost);
+				END LOOP;
+				prev := cur;
+			END LOOP;
+			RETURN prev[char_length(b) + 1];
+		END
+		$$ LANGUAGE plpgsql IMMUTABLE`,
+		`CREATE OR REPLACE FUNCTION match_word(q text, w text) RETURNS double precision AS $$
+		BEGIN
+			IF q = w THEN
+				RETURN 1;
+			ELSIF left(w, char_length(q)) = q THEN
+				RETURN 0.95;
+			ELSIF strpos(w, q) > 0 THEN
+				RETURN 0.85;
+			END IF;
+			RETURN 1 - match_distance(q, w)::double precision / greatest(char_length(q), char_length(w));
+		END
+		$$ LANGUAGE plpgsql IMMUTABLE`,
+		`CREATE OR REPLACE FUNCTION match_score(query text, name text, hometown text) RETURNS double precision AS $$
+		DECLARE
+			q text;
+			w text;
+			s double precision;
+			best double precision;
+			total double precision := 0;
+		BEGIN
+			IF query = '' THEN
+				RETURN 0;
+			END IF;
+			FOREACH q IN ARRAY string_to_array(query, ' ') LOOP
+				best := 0;
+				FOREACH w IN ARRAY string_to_array(name, ' ') LOOP
+					s := match_word(q, w);
+					IF s > best THEN
+						best := s;
+					END IF;
+				END LOOP;
+				FOREACH w IN ARRAY string_to_array(hometown, ' ') LOOP
+					s := match_word(q, w) * 0.8::double precision;
+					IF s > best THEN
+						best := s;
+					END IF;
+				END LOOP;
+				total := total + best;
+			END LOOP;
+			RETURN total / array_length(string_to_array(query, ' '), 1);
+		END
+		$$ LANGUAGE plpgsql IMMUTABLE`,
+	}, rebuildLifterSearch},
+}
+
+// uniqueResults adds the unique url, lifter and hometown index. Databases
+// built by hand before migrations existed may hold duplicates which would
+// block it, all but the first of each are deleted and every deleted row is
+// logged. Only sqlite databases were built by hand.
+func uniqueResults(tx *sql.Tx) error {
+	var duplicates int64
+	err := tx.QueryRow(`SELECT COUNT(*) FROM (SELECT 1 FROM results GROUP BY url, lifter, hometown HAVING COUNT(*) > 1) AS d`).Scan(&duplicates)
+	if err != nil {
+		return err
+	}
+	if duplicates > 0 {
+		if err := removeDuplicateResults(tx); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_url_lifter_hometown ON results(url, lifter, hometown)`)
+	return err
+}
+
+func removeDuplicateResults(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT rowid, lifter, hometown, meet_name, date, total, url FROM results AS r
+		WHERE rowid > (SELECT MIN(rowid) FROM results AS f WHERE f.url = r.url AND f.lifter = r.lifter AND f.hometown = r.hometown)
+		ORDER BY rowid`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var removed []int64
+	for rows.Next() {
+		var rowid int64
+		var lifter, hometown, meetName, date, total, url string
+		if err := rows.Scan(&rowid, &lifter, &hometown, &meetName, &date, &total, &url); err != nil {
+			return err
+		}
+		log.Printf("removing duplicate result %d: %v (%v) at %v on %v, total %v, url %q\n", rowid, lifter, hometown, meetName, date, total, url)
+		removed = append(removed, rowid)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, rowid := range removed {
+		if _, err := tx.Exec(`DELETE FROM results WHERE rowid = $1`, rowid); err != nil {
+			return err
+		}
+	}
+	log.Printf("removed %d duplicate results\n", len(removed))
+	return nil
+}
+
+// LatestVersion is the schema version this build expects.
+func LatestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaTooOldError is returned when a database needs migrations applied
+// before it can be served.
+type SchemaTooOldError struct {
+	Version int
+	Want    int
+}
+
+func (e *SchemaTooOldError) Error() string {
+	return fmt.Sprintf("database schema is version %d but version %d is required, run the migrate command against a writable copy", e.Version, e.Want)
+}
+
+const createSchemaVersion = `CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TEXT NOT NULL
+)`
+
+// SchemaVersion returns the highest migration applied, 0 for a database that
+// has never been migrated.
+func (o *OurDB) SchemaVersion() (int, error) {
+	var exists int
+	q := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`
+	if o.dialect == postgresDialect {
+		q = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_version'`
+	}
+	err := o.db.QueryRow(q).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if exists == 0 {
+		return 0, nil
+	}
+	var version sql.NullInt64
+	err = o.db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// CheckSchema returns a *SchemaTooOldError if migrations are pending.
+func (o *OurDB) CheckSchema() error {
+	v, err := o.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if v < LatestVersion() {
+		return &SchemaTooOldError{Version: v, Want: LatestVersion()}
+	}
+	return nil
+}
+
+// Migrate applies every pending migration in order and returns how many ran.
+// The database must not be opened with _query_only.
+func (o *OurDB) Migrate() (int, error) {
+	if _, err := o.db.Exec(createSchemaVersion); err != nil {
+		return 0, fmt.Errorf("creating schema_version: %v", err)
+	}
+	current, err := o.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := o.apply(m); err != nil {
+			return applied, fmt.Errorf("migration %d (%v): %v", m.version, m.name, err)
+		}
+		log.Printf("applied migration %d: %v\n", m.version, m.name)
+		applied++
+	}
+	return applied, nil
+}
+
+func (o *OurDB) apply(m migration) error {
+	tx, err := o.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range m.statementsFor(o.dialect) {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if m.after != nil {
+		if err := m.after(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	_, err = tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES ($1, $2, $3)`, m.version, m.name, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func tempDBPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "faststats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "results.db"), func() { os.RemoveAll(dir) }
+}
+
+func TestMigrate(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	o, err := OpenDB(path)
+	assert.Nil(t, err)
+	defer o.Close()
+
+	v, err := o.SchemaVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, v)
+
+	err = o.CheckSchema()
+	assert.IsType(t, &SchemaTooOldError{}, err)
+
+	applied, err := o.Migrate()
+	assert.Nil(t, err)
+	assert.Equal(t, len(migrations), applied)
+	assert.Nil(t, o.CheckSchema())
+
+	// running again is a no-op
+	applied, err = o.Migrate()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, applied)
+
+	served, err := BuildDB(path + "?_query_only=1")
+	assert.Nil(t, err, "migrated database should be servable")
+	served.Close()
+}
+
+func TestBuildDBRefusesOldSchema(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	_, err := BuildDB(path)
+	assert.IsType(t, &SchemaTooOldError{}, err)
+}
+
+func TestMigrateHandBuiltDB(t *testing.T) {
+	path, cleanup := tempDBPath(t)
+	defer cleanup()
+
+	o, err := OpenDB(path)
+	assert.Nil(t, err)
+	defer o.Close()
+
+	// the way results.db was built before migrations existed
+	_, err = o.db.Exec(`CREATE TABLE results (date, meet_name, lifter, weight_class, competition_weight, hometown, cj1, cj2, cj3, sn1, sn2, sn3, total, best_snatch, best_cleanjerk, url)`)
+	assert.Nil(t, err)
+	for _, total := range []int{190, 191} {
+		_, err = o.db.Exec(`INSERT INTO results VALUES ('2018-06-20', 'Nationals', 'Chris Wolfe', 'Men''s 77Kg', 76.5, 'Austin, TX', 100, -105, 105, 80, 85, -88, $1, 85, 105, 'http://usaw')`, total)
+		assert.Nil(t, err)
+	}
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	_, err = o.Migrate()
+	assert.Nil(t, err)
+
+	total, err := o.CountResults()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total, "duplicate rows should be removed")
+	assert.Contains(t, logged.String(), "removing duplicate result 2: Chris Wolfe (Austin, TX) at Nationals on 2018-06-20, total 191")
+	assert.Contains(t, logged.String(), "removed 1 duplicate results")
+
+	var sinclair decimal.Decimal
+	err = o.db.QueryRow(`SELECT sinclair FROM results`).Scan(&sinclair)
+	assert.Nil(t, err)
+	assert.True(t, sinclair.GreaterThan(decimal.New(190, 0)), "existing results should be scored, got %v", sinclair)
+}
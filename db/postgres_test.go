@@ -0,0 +1,172 @@
+package db
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/scoring"
+)
+
+func TestParseDSN(t *testing.T) {
+	cases := []struct {
+		dsn     string
+		dialect dialect
+		source  string
+	}{
+		{"./results.db?_query_only=1", sqliteDialect, "./results.db?_query_only=1"},
+		{"sqlite://results.db", sqliteDialect, "results.db"},
+		{"file:test.db?mode=memory", sqliteDialect, "file:test.db?mode=memory"},
+		{"postgres://faststats@localhost/faststats?sslmode=disable", postgresDialect, "postgres://faststats@localhost/faststats?sslmode=disable"},
+		{"postgresql://localhost/faststats", postgresDialect, "postgresql://localhost/faststats"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.dsn, func(t *testing.T) {
+			d, source := parseDSN(tt.dsn)
+			assert.Equal(t, tt.dialect, d)
+			assert.Equal(t, tt.source, source)
+		})
+	}
+}
+
+func TestSQLitePath(t *testing.T) {
+	assert.Equal(t, "./results.db", SQLitePath("./results.db?_query_only=1"))
+	assert.Equal(t, "results.db", SQLitePath("sqlite://results.db"))
+	assert.Equal(t, "test.db", SQLitePath("file:test.db?cache=shared"))
+	assert.Equal(t, "", SQLitePath("file:test.db?mode=memory"))
+	assert.Equal(t, "", SQLitePath(":memory:"))
+	assert.Equal(t, "", SQLitePath("postgres://localhost/faststats"))
+}
+
+func TestReadOnly(t *testing.T) {
+	assert.Equal(t, "/srv/faststats.db?_query_only=1", ReadOnly("/srv/faststats.db"))
+	assert.Equal(t, "file:test.db?cache=shared&_query_only=1", ReadOnly("file:test.db?cache=shared"))
+	assert.Equal(t, "./results.db?_query_only=1", ReadOnly("./results.db?_query_only=1"))
+	assert.Equal(t, "postgres://localhost/faststats", ReadOnly("postgres://localhost/faststats"))
+}
+
+func TestMigratedTables(t *testing.T) {
+	assert.Equal(t, []string{"schema_version", "results", "lifters", "lifter_trigrams", "lifter_aliases", "lifter_words"}, migratedTables())
+}
+
+func TestDialect(t *testing.T) {
+	assert.Equal(t, "like", sqliteDialect.like())
+	assert.Equal(t, "ILIKE", postgresDialect.like())
+	assert.Equal(t, "lifter", sqliteDialect.orderBy("lifter"))
+	assert.Equal(t, `lifter COLLATE "C"`, postgresDialect.orderBy("lifter"))
+}
+
+// migratedTables lists every table the migrations create.
+func migratedTables() []string {
+	tables := []string{"schema_version"}
+	for _, m := range migrations {
+		for _, stmt := range m.statementsFor(postgresDialect) {
+			if match := createTableReg.FindStringSubmatch(stmt); match != nil {
+				tables = append(tables, match[1])
+			}
+		}
+	}
+	return tables
+}
+
+var createTableReg = regexp.MustCompile(`CREATE TABLE IF NOT EXISTS (\w+)`)
+
+// TestPostgresMatchesSqlite loads the fixtures into the postgres database named
+// by FASTSTATS_POSTGRES_DSN and checks every query answers exactly as the
+// sqlite fixtures do. Every table the migrations create is dropped first, so
+// rows left by an earlier run can't leak in. Point it at a scratch database
+// or container.
+func TestPostgresMatchesSqlite(t *testing.T) {
+	dsn := os.Getenv("FASTSTATS_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("FASTSTATS_POSTGRES_DSN not set")
+	}
+	lite, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err)
+	defer lite.Close()
+
+	pg, err := OpenDB(dsn)
+	assert.Nil(t, err)
+	defer pg.Close()
+	for _, table := range migratedTables() {
+		_, err = pg.db.Exec(`DROP TABLE IF EXISTS ` + table)
+		assert.Nil(t, err)
+	}
+
+	f, err := os.Open("testdata/fixtures.jsonl")
+	assert.Nil(t, err)
+	defer f.Close()
+	results, err := ReadFixtures(f)
+	assert.Nil(t, err)
+
+	_, err = pg.Migrate()
+	assert.Nil(t, err)
+	assert.Nil(t, pg.UpsertResults(results))
+	// idempotent like sqlite
+	assert.Nil(t, pg.UpsertResults(results))
+	assert.Nil(t, pg.CheckSchema())
+
+	for _, store := range []*OurDB{lite, pg} {
+		total, err := store.CountResults()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(results)), total)
+	}
+
+	for _, q := range []struct{ name, page string }{{"steph", "1"}, {"steph", "2"}, {"STEPH", ""}, {"j bradley", "1"}, {"foooooo", "1"}} {
+		want, err := lite.QueryNames(q.name, q.page)
+		assert.Nil(t, err)
+		got, err := pg.QueryNames(q.name, q.page)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got, "QueryNames(%v, %v)", q.name, q.page)
+	}
+
+	for _, l := range []Lifter{{Name: "Chris Wolfe", Hometown: "Austin, TX"}, {Name: "Kyle Brown", Hometown: "Portland, OR"}, {Name: "Nobody", Hometown: "Nowhere"}} {
+		want, err := lite.QueryResults(l.Name, l.Hometown)
+		assert.Nil(t, err)
+		got, err := pg.QueryResults(l.Name, l.Hometown)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got, "QueryResults(%v)", l)
+	}
+
+	wantMeets, err := lite.QueryMeets(MeetFilter{Name: "american open", From: "2017-01-01"}, "1")
+	assert.Nil(t, err)
+	gotMeets, err := pg.QueryMeets(MeetFilter{Name: "american open", From: "2017-01-01"}, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, wantMeets, gotMeets)
+
+	for _, m := range Metrics {
+		f := RankingFilter{Metric: m}
+		want, err := lite.QueryRankings(f, "2")
+		assert.Nil(t, err)
+		got, err := pg.QueryRankings(f, "2")
+		assert.Nil(t, err)
+		assert.Equal(t, want, got, "QueryRankings(%v)", m)
+	}
+
+	wantRecords, err := lite.QueryRecords(RecordFilter{Gender: scoring.Female})
+	assert.Nil(t, err)
+	gotRecords, err := pg.QueryRecords(RecordFilter{Gender: scoring.Female})
+	assert.Nil(t, err)
+	assert.Equal(t, wantRecords, gotRecords)
+
+	for _, q := range []string{"steph", "mo", "rog mat", "zzz"} {
+		want, err := lite.Suggest(q, 5)
+		assert.Nil(t, err)
+		got, err := pg.Suggest(q, 5)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got, "Suggest(%v)", q)
+	}
+
+	wantHometowns, err := lite.QueryHometowns("", "")
+	assert.Nil(t, err)
+	gotHometowns, err := pg.QueryHometowns("", "")
+	assert.Nil(t, err)
+	assert.Equal(t, wantHometowns, gotHometowns)
+
+	wantHometown, err := lite.QueryHometown("Springfield, IL")
+	assert.Nil(t, err)
+	gotHometown, err := pg.QueryHometown("Springfield, IL")
+	assert.Nil(t, err)
+	assert.Equal(t, wantHometown, gotHometown)
+}
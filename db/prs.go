@@ -0,0 +1,105 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// Lift names what a personal record was set in.
+type Lift string
+
+const (
+	LiftSnatch    Lift = "snatch"
+	LiftCleanJerk Lift = "cleanjerk"
+	LiftTotal     Lift = "total"
+)
+
+// PR is a personal record set at a meet.
+type PR struct {
+	Date     string `json:"date"`
+	MeetName string `json:"meet_name"`
+	Lift     Lift   `json:"lift"`
+	// Weightclass is set for records that are only the best in that class
+	Weightclass string          `json:"weight_class,omitempty"`
+	Value       decimal.Decimal `json:"value"`
+	// Previous is zero for the first result in a lift
+	Previous decimal.Decimal `json:"previous"`
+	Delta    decimal.Decimal `json:"delta"`
+}
+
+// First reports whether the PR is the lifter's first result in the lift.
+func (p PR) First() bool {
+	return p.Previous.IsZero()
+}
+
+// PRHistory is every PR a lifter has set, oldest first.
+type PRHistory struct {
+	Lifter   string `json:"lifter"`
+	Hometown string `json:"hometown"`
+	PRs      []PR   `json:"prs"`
+}
+
+// prTracker holds the bests so far for each lift.
+type prTracker struct {
+	best    map[Lift]decimal.Decimal
+	inClass map[string]map[Lift]decimal.Decimal
+}
+
+// check records value and returns the PR it sets, if any. Overall records
+// take precedence, a weight class record is only returned when value beats
+// an earlier result in the same class without being an overall record.
+func (t *prTracker) check(r *Result, lift Lift, value decimal.Decimal) (PR, bool) {
+	if value.Sign() <= 0 {
+		return PR{}, false
+	}
+	class := t.inClass[r.Weightclass]
+	if class == nil {
+		class = map[Lift]decimal.Decimal{}
+		t.inClass[r.Weightclass] = class
+	}
+	prevClass, prev := class[lift], t.best[lift]
+	if value.GreaterThan(prevClass) {
+		class[lift] = value
+	}
+
+	pr := PR{Date: r.Date, MeetName: r.MeetName, Lift: lift, Value: value}
+	switch {
+	case value.GreaterThan(prev):
+		t.best[lift] = value
+		pr.Previous = prev
+	case prevClass.Sign() > 0 && value.GreaterThan(prevClass):
+		pr.Weightclass = r.Weightclass
+		pr.Previous = prevClass
+	default:
+		return PR{}, false
+	}
+	if !pr.Previous.IsZero() {
+		pr.Delta = value.Sub(pr.Previous)
+	}
+	return pr, true
+}
+
+// markPRs walks results oldest first, setting PRs on every result that set
+// one, and returns the history.
+func markPRs(results []*Result) []PR {
+	ordered := make([]*Result, len(results))
+	copy(ordered, results)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Date < ordered[j].Date })
+
+	t := &prTracker{best: map[Lift]decimal.Decimal{}, inClass: map[string]map[Lift]decimal.Decimal{}}
+	history := []PR{}
+	for _, r := range ordered {
+		r.PRs = nil
+		for _, l := range []struct {
+			lift  Lift
+			value decimal.Decimal
+		}{{LiftSnatch, r.BestSN}, {LiftCleanJerk, r.BestCJ}, {LiftTotal, r.Total}} {
+			if pr, ok := t.check(r, l.lift, l.value); ok {
+				r.PRs = append(r.PRs, pr)
+				history = append(history, pr)
+			}
+		}
+	}
+	return history
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkPRsWeightClass(t *testing.T) {
+	total := func(date, class, total string) *Result {
+		return &Result{Date: date, Weightclass: class, Total: decimal.RequireFromString(total)}
+	}
+	results := []*Result{
+		total("2019-01-01", "Men's 69Kg", "210"),
+		total("2018-01-01", "Men's 77Kg", "220"),
+		total("2017-01-01", "Men's 69Kg", "200"),
+	}
+	history := markPRs(results)
+	assert.Len(t, history, 3)
+	assert.Equal(t, "Men's 69Kg", history[2].Weightclass)
+	assert.Equal(t, "10", history[2].Delta.String())
+	assert.Equal(t, history[2], results[0].PRs[0], "the result is marked too")
+}
+
+func TestPRHistory(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	rs, err := db.QueryResults("Chris Wolfe", "Austin, TX")
+	assert.Nil(t, err)
+	assert.Len(t, rs.PRHistory, 8)
+
+	first := rs.PRHistory[0]
+	assert.Equal(t, "2017-03-04", first.Date)
+	assert.True(t, first.First())
+
+	// the latest meet set clean & jerk and total PRs but not a snatch PR
+	latest := rs.Results[0]
+	assert.Len(t, latest.PRs, 2)
+	assert.Equal(t, LiftCleanJerk, latest.PRs[0].Lift)
+	assert.Equal(t, "5", latest.PRs[0].Delta.String())
+	assert.Equal(t, LiftTotal, latest.PRs[1].Lift)
+	assert.Equal(t, "2", latest.PRs[1].Delta.String())
+}
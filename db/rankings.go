@@ -0,0 +1,297 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+	"gitlab.com/derwolfe/faststats/scoring"
+	"gitlab.com/derwolfe/faststats/weightclass"
+)
+
+// Metric is the value lifters are ranked by.
+type Metric string
+
+const (
+	MetricTotal     Metric = "total"
+	MetricSnatch    Metric = "snatch"
+	MetricCleanJerk Metric = "cleanjerk"
+	MetricSinclair  Metric = "sinclair"
+)
+
+// Metrics lists every supported ranking metric.
+var Metrics = []Metric{MetricTotal, MetricSnatch, MetricCleanJerk, MetricSinclair}
+
+// column returns the results column holding the metric. Sinclair scores are
+// computed when results are stored.
+func (m Metric) column() string {
+	switch m {
+	case MetricSnatch:
+		return "best_snatch"
+	case MetricCleanJerk:
+		return "best_cleanjerk"
+	case MetricSinclair:
+		return "sinclair"
+	}
+	return "total"
+}
+
+// Valid reports whether m is a known metric.
+func (m Metric) Valid() bool {
+	for _, known := range Metrics {
+		if m == known {
+			return true
+		}
+	}
+	return false
+}
+
+// RankingFilter narrows the results considered for a ranking. Empty fields
+// are ignored. ModernClass is a 2018 category such as "Men's 81Kg", results
+// from older categories are matched to it by bodyweight.
+type RankingFilter struct {
+	Year int `json:"year,omitempty"`
+	// From and To are inclusive YYYY-MM-DD dates
+	From        string         `json:"from,omitempty"`
+	To          string         `json:"to,omitempty"`
+	Weightclass string         `json:"weight_class,omitempty"`
+	ModernClass string         `json:"modern_class,omitempty"`
+	Gender      scoring.Gender `json:"-"`
+	Metric      Metric         `json:"metric"`
+}
+
+// Ranking is a lifter's best result within a RankingFilter.
+type Ranking struct {
+	Rank              int64           `json:"rank"`
+	Lifter            string          `json:"lifter"`
+	Hometown          string          `json:"hometown"`
+	Value             decimal.Decimal `json:"value"`
+	Date              string          `json:"date"`
+	MeetName          string          `json:"meet_name"`
+	Weightclass       string          `json:"weight_class"`
+	CompetitionWeight decimal.Decimal `json:"competition_weight"`
+	BestSN            decimal.Decimal `json:"best_sn"`
+	BestCJ            decimal.Decimal `json:"best_cj"`
+	Total             decimal.Decimal `json:"total"`
+	Sinclair          decimal.Decimal `json:"sinclair"`
+}
+
+type RankingsResponse struct {
+	Rankings   []Ranking     `json:"rankings"`
+	Filter     RankingFilter `json:"filter"`
+	Gender     string        `json:"gender"`
+	Total      int64         `json:"total"`
+	Pages      []PageInfo    `json:"pages"`
+	Current    int64         `json:"current"`
+	TotalPages int64         `json:"total_pages"`
+}
+
+// genderClause matches weight classes for a gender, mirroring
+// scoring.GenderFromWeightClass closely enough for the USAW data.
+func genderClause(g scoring.Gender) string {
+	female := `(lower(weight_class) like '%women%' OR lower(weight_class) like '%female%' OR lower(weight_class) like 'w %' OR lower(weight_class) like 'f %')`
+	switch g {
+	case scoring.Female:
+		return female
+	case scoring.Male:
+		return `(NOT ` + female + ` AND (lower(weight_class) like '%men%' OR lower(weight_class) like '%male%' OR lower(weight_class) like 'm %'))`
+	}
+	return ""
+}
+
+func (f RankingFilter) where() *where {
+	w := &where{}
+	if f.Year != 0 {
+		w.add("date >= ?", fmt.Sprintf("%04d-01-01", f.Year))
+		w.add("date <= ?", fmt.Sprintf("%04d-12-31", f.Year))
+	}
+	if f.From != "" {
+		w.add("date >= ?", f.From)
+	}
+	if f.To != "" {
+		w.add("date <= ?", f.To)
+	}
+	if f.Weightclass != "" {
+		w.add("weight_class = ?", f.Weightclass)
+	}
+	if f.ModernClass != "" {
+		addModernClass(w, f.ModernClass)
+	}
+	if c := genderClause(f.Gender); c != "" {
+		w.add(c)
+	}
+	// only ranked lifts count, bomb outs are zero or negative
+	w.add(f.Metric.column() + " > 0")
+	return w
+}
+
+const rankingColumns = `lifter, hometown, date, meet_name, weight_class, competition_weight, best_snatch, best_cleanjerk, total, sinclair`
+
+func scanRanking(rows interface{ Scan(...interface{}) error }) (Ranking, error) {
+	r := Ranking{}
+	err := rows.Scan(&r.Lifter, &r.Hometown, &r.Date, &r.MeetName, &r.Weightclass, &r.CompetitionWeight, &r.BestSN, &r.BestCJ, &r.Total, &r.Sinclair, &r.Rank)
+	return r, err
+}
+
+func (r *Ranking) setValue(m Metric) {
+	switch m {
+	case MetricSnatch:
+		r.Value = r.BestSN
+	case MetricCleanJerk:
+		r.Value = r.BestCJ
+	case MetricSinclair:
+		r.Value = r.Sinclair
+	default:
+		r.Value = r.Total
+	}
+}
+
+// fillSinclair stores the Sinclair score of every result already imported.
+// Results are read before updating since postgres can't interleave the two
+// on one connection.
+func fillSinclair(tx *sql.Tx) error {
+	type scored struct {
+		url, lifter, hometown string
+		sinclair              decimal.Decimal
+	}
+	rows, err := tx.Query(`SELECT url, lifter, hometown, date, weight_class, competition_weight, total FROM results`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var all []scored
+	for rows.Next() {
+		var s scored
+		var date, weightClass string
+		var bodyweight, total decimal.Decimal
+		if err := rows.Scan(&s.url, &s.lifter, &s.hometown, &date, &weightClass, &bodyweight, &total); err != nil {
+			return err
+		}
+		s.sinclair = scoring.Sinclair(total, bodyweight, date, weightClass)
+		all = append(all, s)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	update, err := tx.Prepare(`UPDATE results SET sinclair = $1 WHERE url = $2 AND lifter = $3 AND hometown = $4`)
+	if err != nil {
+		return err
+	}
+	defer update.Close()
+	for _, s := range all {
+		if _, err := update.Exec(s.sinclair, s.url, s.lifter, s.hometown); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryRankings returns a page of the best result per lifter and hometown
+// within the filter, ordered by the filter's metric. Tied lifters share a
+// rank and the lighter lifter is listed first.
+func (o *OurDB) QueryRankings(f RankingFilter, offset string) (*RankingsResponse, error) {
+	logQuery("rankings: %+v, offset: %v\n", f, offset)
+	if f.Metric == "" {
+		f.Metric = MetricTotal
+	}
+	if !f.Metric.Valid() {
+		return nil, fmt.Errorf("unknown metric %q", f.Metric)
+	}
+	if f.ModernClass != "" {
+		if _, err := ParseModernClass(f.ModernClass); err != nil {
+			return nil, err
+		}
+	}
+	resp := &RankingsResponse{Filter: f}
+	if f.Gender != scoring.Unknown {
+		resp.Gender = f.Gender.String()
+	}
+
+	w := f.where()
+	err := o.db.QueryRow(`SELECT COUNT(*) FROM (SELECT 1 FROM results`+w.String()+` GROUP BY lifter, hometown) AS lifters`, w.args...).Scan(&resp.Total)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Total == 0 {
+		return resp, nil
+	}
+	onum, numPages, pages := pageRange(resp.Total, offset)
+
+	col := f.Metric.column()
+	q := `SELECT ` + rankingColumns + `, RANK() OVER (ORDER BY ` + col + ` DESC) AS place FROM (SELECT ` + rankingColumns + `, ROW_NUMBER() OVER (PARTITION BY lifter, hometown ORDER BY ` + col + ` DESC, competition_weight ASC, date ASC) AS rn FROM results` + w.String() +
+		`) AS best WHERE rn = 1 ORDER BY ` + col + ` DESC, competition_weight ASC, ` + o.dialect.orderBy("lifter") + ` ASC LIMIT ` + w.next(pageLimit) + ` OFFSET ` + w.next((onum-1)*pageLimit)
+	rows, err := o.db.Query(q, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rankings := make([]Ranking, 0, getPageSize(onum, resp.Total, pageLimit))
+	for rows.Next() {
+		r, err := scanRanking(rows)
+		if err != nil {
+			return nil, err
+		}
+		r.setValue(f.Metric)
+		rankings = append(rankings, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	resp.Rankings = rankings
+	resp.Current, resp.Pages, resp.TotalPages = onum, pages, numPages
+	return resp, nil
+}
+
+// ParseGender converts a gender query parameter into a scoring.Gender.
+func ParseGender(s string) (scoring.Gender, error) {
+	switch s {
+	case "":
+		return scoring.Unknown, nil
+	case "female", "women", "f", "w":
+		return scoring.Female, nil
+	case "male", "men", "m":
+		return scoring.Male, nil
+	}
+	return scoring.Unknown, fmt.Errorf("unknown gender %q", s)
+}
+
+// ParseModernClass parses a 2018 category such as "Men's 81Kg".
+func ParseModernClass(s string) (weightclass.Class, error) {
+	c, err := weightclass.Parse(s, "")
+	if err != nil {
+		return c, err
+	}
+	if c.Era != weightclass.Modern {
+		return c, fmt.Errorf("%q isn't a %v weight class", s, weightclass.Modern)
+	}
+	return c, nil
+}
+
+// addModernClass matches results whose bodyweight falls in a modern class.
+// The class must already have been checked with ParseModernClass. Results
+// without a bodyweight can't be placed and never match.
+func addModernClass(w *where, modern string) {
+	c, _ := ParseModernClass(modern)
+	w.add(genderClause(c.Gender))
+	w.add("competition_weight > ?", c.Floor())
+	if !c.Unlimited {
+		w.add("competition_weight <= ?", c.Limit)
+	}
+}
+
+// ParseYear converts a year query parameter, 0 means any year.
+func ParseYear(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	y, err := strconv.Atoi(s)
+	if err != nil || y < 1900 || y > 9999 {
+		return 0, fmt.Errorf("invalid year %q", s)
+	}
+	return y, nil
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/scoring"
+)
+
+func TestRankingFilterWhere(t *testing.T) {
+	w := RankingFilter{Year: 2018, Weightclass: "Women's 63Kg", Metric: MetricSnatch}.where()
+	assert.Equal(t, " WHERE date >= $1 AND date <= $2 AND weight_class = $3 AND best_snatch > 0", w.String())
+	assert.Equal(t, []interface{}{"2018-01-01", "2018-12-31", "Women's 63Kg"}, w.args)
+
+	w = RankingFilter{Gender: scoring.Female, Metric: MetricSinclair}.where()
+	assert.Contains(t, w.String(), "women")
+	assert.Contains(t, w.String(), "sinclair > 0")
+}
+
+func TestParseGender(t *testing.T) {
+	g, err := ParseGender("women")
+	assert.Nil(t, err)
+	assert.Equal(t, scoring.Female, g)
+
+	g, err = ParseGender("")
+	assert.Nil(t, err)
+	assert.Equal(t, scoring.Unknown, g)
+
+	_, err = ParseGender("unknown")
+	assert.NotNil(t, err)
+}
+
+func TestParseYear(t *testing.T) {
+	y, err := ParseYear("2018")
+	assert.Nil(t, err)
+	assert.Equal(t, 2018, y)
+
+	y, err = ParseYear("")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, y)
+
+	_, err = ParseYear("18x")
+	assert.NotNil(t, err)
+}
+
+func TestQueryRankings(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	r, err := db.QueryRankings(RankingFilter{Gender: scoring.Male, Metric: MetricTotal}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "D'Angelo Osorio", r.Rankings[0].Lifter)
+	assert.Equal(t, "306", r.Rankings[0].Value.String(), "a lifter's best total is used")
+
+	// one row per lifter and hometown
+	seen := map[identity]bool{}
+	for _, rk := range r.Rankings {
+		key := identity{rk.Lifter, rk.Hometown}
+		assert.False(t, seen[key], "%v ranked twice", key)
+		seen[key] = true
+	}
+
+	r, err = db.QueryRankings(RankingFilter{Metric: MetricSinclair}, "2")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), r.Current)
+	assert.Equal(t, int64(51), r.Rankings[0].Rank)
+
+	_, err = db.QueryRankings(RankingFilter{Metric: "bench"}, "")
+	assert.NotNil(t, err)
+}
+
+func TestQueryRankingsModernClass(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	r, err := db.QueryRankings(RankingFilter{ModernClass: "Men's 81Kg", Metric: MetricTotal}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "D'Angelo Osorio", r.Rankings[0].Lifter)
+	for _, rk := range r.Rankings {
+		assert.True(t, rk.CompetitionWeight.GreaterThan(decimal.New(73, 0)), "%v weighed %v", rk.Lifter, rk.CompetitionWeight)
+	}
+
+	_, err = db.QueryRankings(RankingFilter{ModernClass: "Men's 77Kg"}, "")
+	assert.NotNil(t, err)
+}
+
+func TestQueryRankingsTies(t *testing.T) {
+	lift := func(lifter string, bodyweight, total int64) *Result {
+		return &Result{
+			Date:              "2018-06-20",
+			MeetName:          "Nationals",
+			Lifter:            lifter,
+			Hometown:          "Austin, TX",
+			Weightclass:       "Men's 81Kg",
+			CompetitionWeight: decimal.New(bodyweight, 0),
+			Total:             decimal.New(total, 0),
+			URL:               "http://usaw/" + lifter,
+		}
+	}
+	db, err := NewMemoryDB([]*Result{
+		lift("c", 80, 250),
+		lift("a", 79, 260),
+		lift("b", 78, 250),
+		lift("d", 80, 250),
+		lift("e", 81, 240),
+	})
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	r, err := db.QueryRankings(RankingFilter{Metric: MetricTotal}, "")
+	assert.Nil(t, err)
+	var order []string
+	var ranks []int64
+	for _, rk := range r.Rankings {
+		order = append(order, rk.Lifter)
+		ranks = append(ranks, rk.Rank)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, order, "lighter lifters are listed first on ties")
+	assert.Equal(t, []int64{1, 2, 2, 2, 5}, ranks)
+
+	// c and d share a bodyweight so their Sinclair ties too
+	r, err = db.QueryRankings(RankingFilter{Metric: MetricSinclair}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", r.Rankings[0].Lifter)
+	assert.Equal(t, r.Rankings[2].Rank, r.Rankings[3].Rank)
+	assert.Equal(t, r.Rankings[2].Value, r.Rankings[3].Value)
+	assert.True(t, r.Rankings[0].Sinclair.Equal(r.Rankings[0].Value))
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/shopspring/decimal"
+	"gitlab.com/derwolfe/faststats/scoring"
+)
+
+// Lifts lists every lift records are kept for.
+var Lifts = []Lift{LiftSnatch, LiftCleanJerk, LiftTotal}
+
+// Valid reports whether l is a known lift.
+func (l Lift) Valid() bool {
+	for _, known := range Lifts {
+		if l == known {
+			return true
+		}
+	}
+	return false
+}
+
+func (l Lift) column() string {
+	switch l {
+	case LiftSnatch:
+		return "best_snatch"
+	case LiftCleanJerk:
+		return "best_cleanjerk"
+	}
+	return "total"
+}
+
+// RecordFilter narrows the records returned. Empty fields are ignored.
+type RecordFilter struct {
+	Gender      scoring.Gender `json:"-"`
+	Weightclass string         `json:"weight_class,omitempty"`
+	ModernClass string         `json:"modern_class,omitempty"`
+	Lift        Lift           `json:"lift,omitempty"`
+}
+
+// Record is a lift that beat every earlier lift in its weight class.
+type Record struct {
+	Date     string          `json:"date"`
+	MeetName string          `json:"meet_name"`
+	Lifter   string          `json:"lifter"`
+	Hometown string          `json:"hometown"`
+	Value    decimal.Decimal `json:"value"`
+	// Previous is the record that was broken, zero for the first
+	Previous decimal.Decimal `json:"previous"`
+}
+
+// RecordProgression is the history of one record, oldest first. Current is
+// the standing record.
+type RecordProgression struct {
+	Weightclass string   `json:"weight_class"`
+	Gender      string   `json:"gender"`
+	Lift        Lift     `json:"lift"`
+	Current     Record   `json:"current"`
+	History     []Record `json:"history"`
+}
+
+type RecordsResponse struct {
+	Records []*RecordProgression `json:"records"`
+	Filter  RecordFilter         `json:"filter"`
+	Gender  string               `json:"gender"`
+}
+
+// QueryRecords returns the record progression for every weight class and
+// lift matching the filter. Records are only as good as the data: they are
+// the best lifts in this database, not official records. Weight classes are
+// taken as USAW wrote them, so age groups named in a class are kept apart.
+func (o *OurDB) QueryRecords(f RecordFilter) (*RecordsResponse, error) {
+	logQuery("records: %+v\n", f)
+	if f.Lift != "" && !f.Lift.Valid() {
+		return nil, fmt.Errorf("unknown lift %q", f.Lift)
+	}
+	if f.ModernClass != "" {
+		if _, err := ParseModernClass(f.ModernClass); err != nil {
+			return nil, err
+		}
+	}
+	resp := &RecordsResponse{Filter: f}
+	if f.Gender != scoring.Unknown {
+		resp.Gender = f.Gender.String()
+	}
+
+	lifts := Lifts
+	if f.Lift != "" {
+		lifts = []Lift{f.Lift}
+	}
+	for _, l := range lifts {
+		progressions, err := o.recordProgressions(f, l)
+		if err != nil {
+			return nil, err
+		}
+		resp.Records = append(resp.Records, progressions...)
+	}
+
+	liftOrder := map[Lift]int{}
+	for i, l := range Lifts {
+		liftOrder[l] = i
+	}
+	sort.SliceStable(resp.Records, func(i, j int) bool {
+		a, b := resp.Records[i], resp.Records[j]
+		if a.Weightclass != b.Weightclass {
+			return weightClassLess(a.Weightclass, b.Weightclass)
+		}
+		return liftOrder[a.Lift] < liftOrder[b.Lift]
+	})
+	return resp, nil
+}
+
+// recordProgressions finds every record broken in one lift. A result breaks
+// the record when it beats the best earlier result in its class. Results on
+// the same day are ordered heaviest first, so only the best lift of a meet
+// counts. With a modern class every matching result competes in that class
+// whatever category it was lifted in.
+func (o *OurDB) recordProgressions(f RecordFilter, l Lift) ([]*RecordProgression, error) {
+	w := &where{}
+	if f.Weightclass != "" {
+		w.add("weight_class = ?", f.Weightclass)
+	}
+	partition, order, label := "PARTITION BY weight_class ", "weight_class, date", ""
+	if f.ModernClass != "" {
+		addModernClass(w, f.ModernClass)
+		c, _ := ParseModernClass(f.ModernClass)
+		partition, order, label = "", "date, value", c.String()
+	}
+	if c := genderClause(f.Gender); c != "" {
+		w.add(c)
+	}
+	col := l.column()
+	w.add(col + " > 0")
+
+	q := `SELECT weight_class, date, meet_name, lifter, hometown, value FROM (SELECT weight_class, date, meet_name, lifter, hometown, ` + col + ` AS value, MAX(` + col + `) OVER (` + partition + `ORDER BY date, ` + col + ` DESC ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING) AS previous FROM results` + w.String() +
+		`) AS ordered WHERE previous IS NULL OR value > previous ORDER BY ` + order
+	rows, err := o.db.Query(q, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var progressions []*RecordProgression
+	var cur *RecordProgression
+	for rows.Next() {
+		var class string
+		r := Record{}
+		if err := rows.Scan(&class, &r.Date, &r.MeetName, &r.Lifter, &r.Hometown, &r.Value); err != nil {
+			return nil, err
+		}
+		if label != "" {
+			class = label
+		}
+		if cur == nil || cur.Weightclass != class {
+			cur = &RecordProgression{Weightclass: class, Lift: l}
+			if g := scoring.GenderFromWeightClass(class); g != scoring.Unknown {
+				cur.Gender = g.String()
+			}
+			progressions = append(progressions, cur)
+		} else {
+			r.Previous = cur.Current.Value
+		}
+		cur.History = append(cur.History, r)
+		cur.Current = r
+	}
+	return progressions, rows.Err()
+}
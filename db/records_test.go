@@ -0,0 +1,56 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/scoring"
+)
+
+func TestQueryRecords(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	r, err := db.QueryRecords(RecordFilter{Weightclass: "Men's 77Kg", Lift: LiftTotal})
+	assert.Nil(t, err)
+	assert.Len(t, r.Records, 1)
+	p := r.Records[0]
+	assert.Equal(t, "male", p.Gender)
+	assert.Len(t, p.History, 2, "a lighter total at the same meet isn't a record")
+	assert.Equal(t, "Chris Wolfe", p.History[0].Lifter)
+	assert.True(t, p.History[0].Previous.IsZero())
+	assert.Equal(t, "D'Angelo Osorio", p.Current.Lifter)
+	assert.Equal(t, "296", p.Current.Value.String())
+	assert.Equal(t, "180", p.Current.Previous.String())
+
+	r, err = db.QueryRecords(RecordFilter{Gender: scoring.Female})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, r.Records)
+	for i, p := range r.Records {
+		assert.Equal(t, "female", p.Gender)
+		if i > 0 {
+			assert.False(t, weightClassLess(p.Weightclass, r.Records[i-1].Weightclass), "records are ordered by weight class")
+		}
+	}
+
+	_, err = db.QueryRecords(RecordFilter{Lift: "bench"})
+	assert.NotNil(t, err)
+}
+
+func TestQueryRecordsModernClass(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	r, err := db.QueryRecords(RecordFilter{ModernClass: "men's 81kg", Lift: LiftTotal})
+	assert.Nil(t, err)
+	assert.Len(t, r.Records, 1, "77 and 81 kg results are one modern class")
+	p := r.Records[0]
+	assert.Equal(t, "Men's 81Kg", p.Weightclass)
+	assert.Equal(t, "Chris Wolfe", p.History[0].Lifter)
+	assert.Equal(t, "306", p.Current.Value.String())
+
+	_, err = db.QueryRecords(RecordFilter{ModernClass: "Men's 77Kg"})
+	assert.NotNil(t, err, "77 kg isn't a modern class")
+}
@@ -0,0 +1,326 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"unicode"
+)
+
+// minMatchScore is the lowest score a lifter can have and still be returned
+// by a name search.
+const minMatchScore = 0.7
+
+// hometownWeight discounts query words that only match a lifter's hometown.
+// match_score repeats it for postgres, see migration 8.
+const hometownWeight = 0.8
+
+var accents = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'ç': "c", 'ć': "c", 'č': "c",
+	'ď': "d", 'đ': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i",
+	'ł': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'ř': "r",
+	'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss",
+	'ť': "t", 'ţ': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+	'ý': "y", 'ÿ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+	'æ': "ae", 'œ': "oe",
+}
+
+// normalizeName lower cases s, folds accents and drops punctuation so that
+// "D'Angelo Osório" and "dangelo osorio" compare equal. Hyphens and other
+// separators become spaces.
+func normalizeName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if folded, ok := accents[r]; ok {
+			b.WriteString(folded)
+			continue
+		}
+		switch {
+		case r == '\'' || r == '’' || r == '.':
+			// D'Angelo, Jr.
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// trigrams returns the distinct trigrams of each word in s, padded the same
+// way as postgres' pg_trgm so short words still produce trigrams.
+func trigrams(s string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, word := range strings.Fields(s) {
+		r := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			t := string(r[i : i+3])
+			if !seen[t] {
+				seen[t] = true
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// wordSimilarity scores how well a query word matches a word of a name, from
+// 0 to 1. Prefixes score highly so initials and partial names still match.
+// Postgres runs match_word from migration 8 instead, changing the scoring
+// needs a migration replacing it.
+func wordSimilarity(q, w string) float64 {
+	switch {
+	case q == w:
+		return 1
+	case strings.HasPrefix(w, q):
+		return 0.95
+	case strings.Contains(w, q):
+		return 0.85
+	}
+	longest := len([]rune(q))
+	if l := len([]rune(w)); l > longest {
+		longest = l
+	}
+	return 1 - float64(levenshtein(q, w))/float64(longest)
+}
+
+// matchScore averages the best match of every query word against the name
+// and hometown words, so word order doesn't matter.
+func matchScore(query, name, hometown string) float64 {
+	qs := strings.Fields(query)
+	if len(qs) == 0 {
+		return 0
+	}
+	names, towns := strings.Fields(name), strings.Fields(hometown)
+	total := 0.0
+	for _, q := range qs {
+		best := 0.0
+		for _, w := range names {
+			if s := wordSimilarity(q, w); s > best {
+				best = s
+			}
+		}
+		for _, w := range towns {
+			if s := wordSimilarity(q, w) * hometownWeight; s > best {
+				best = s
+			}
+		}
+		total += best
+	}
+	return total / float64(len(qs))
+}
+
+// lifterMatches adds the query selecting every lifter matching name to w,
+// or returns "" when name has nothing to search for. Rows are lifter,
+// hometown and score, aliases folded onto their canonical athlete with the
+// best score of any of their identities. Candidates come from the trigram
+// index, and the FTS5 name index when there is one, then match_score ranks
+// every one of them.
+func (o *OurDB) lifterMatches(w *where, name string) string {
+	query := normalizeName(name)
+	grams := trigrams(query)
+	if len(grams) == 0 {
+		return ""
+	}
+
+	// sqlite numbers placeholders in the order they appear
+	score := `match_score(` + w.next(query) + `, l.search_name, l.search_hometown)`
+	placeholders := make([]string, len(grams))
+	for i, g := range grams {
+		placeholders[i] = w.next(g)
+	}
+	// require a third of the trigrams to be shared, typos still share most
+	candidates := `SELECT lifter_id FROM lifter_trigrams WHERE trigram IN (` + strings.Join(placeholders, ", ") + `) GROUP BY lifter_id HAVING COUNT(*) >= ` + w.next(len(grams)/3)
+	if o.hasNameIndex() {
+		candidates += ` UNION SELECT rowid FROM lifter_names WHERE lifter_names MATCH ` + w.next(prefixQuery(query))
+	}
+	return `SELECT lifter, hometown, MAX(score) AS score FROM (SELECT COALESCE(a.canonical_lifter, l.lifter) AS lifter, COALESCE(a.canonical_hometown, l.hometown) AS hometown, ` + score + ` AS score
+		FROM lifters l LEFT JOIN lifter_aliases a ON a.lifter = l.lifter AND a.hometown = l.hometown
+		WHERE l.id IN (` + candidates + `)) AS candidates GROUP BY lifter, hometown HAVING MAX(score) >= ` + w.next(minMatchScore)
+}
+
+// prefixQuery is an FTS5 query matching any word starting with a word of the
+// normalized query, which only holds letters and digits.
+func prefixQuery(query string) string {
+	words := strings.Fields(query)
+	for i, word := range words {
+		words[i] = `"` + word + `"*`
+	}
+	return strings.Join(words, " OR ")
+}
+
+// hasNameIndex reports whether the FTS5 lifter_names index can be searched,
+// go-sqlite3 has to be built with FTS5 and so had the build that migrated
+// the database.
+func (o *OurDB) hasNameIndex() bool {
+	o.nameIndexOnce.Do(func() {
+		if !fts5Enabled || o.dialect != sqliteDialect {
+			return
+		}
+		var tables int
+		err := o.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'lifter_names'`).Scan(&tables)
+		o.nameIndex = err == nil && tables > 0
+	})
+	return o.nameIndex
+}
+
+// searchLifters returns the lifters matching name ordered by relevance, limit
+// of them after skipping offset or all of them when limit is 0, along with
+// how many match in total.
+func (o *OurDB) searchLifters(name string, limit, offset int64) ([]Lifter, int64, error) {
+	w := &where{}
+	matches := o.lifterMatches(w, name)
+	if matches == "" {
+		return nil, 0, nil
+	}
+	q := `SELECT lifter, hometown, score, COUNT(*) OVER () FROM (` + matches + `) AS matches ORDER BY score DESC, ` + o.dialect.orderBy("lifter") + `, ` + o.dialect.orderBy("hometown")
+	if limit > 0 {
+		q += ` LIMIT ` + w.next(limit) + ` OFFSET ` + w.next(offset)
+	}
+	rows, err := o.db.Query(q, w.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var found []Lifter
+	var total int64
+	for rows.Next() {
+		var l Lifter
+		if err := rows.Scan(&l.Name, &l.Hometown, &l.Score, &total); err != nil {
+			return nil, 0, err
+		}
+		found = append(found, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	found, err = o.listHometowns(found)
+	return found, total, err
+}
+
+// countLifters counts the lifters matching name.
+func (o *OurDB) countLifters(name string) (int64, error) {
+	w := &where{}
+	matches := o.lifterMatches(w, name)
+	if matches == "" {
+		return 0, nil
+	}
+	var total int64
+	err := o.db.QueryRow(`SELECT COUNT(*) FROM (`+matches+`) AS matches`, w.args...).Scan(&total)
+	return total, err
+}
+
+// rebuildLifterSearch rebuilds every lifter search index.
+func rebuildLifterSearch(tx *sql.Tx) error {
+	if err := rebuildSearchIndex(tx); err != nil {
+		return err
+	}
+	return rebuildPrefixIndex(tx)
+}
+
+// rebuildSearchIndex repopulates the lifters and lifter_trigrams tables from
+// results, and lifter_names through its triggers. Imports keep them up to
+// date with updateSearchIndexes. The trigram table is what finds typos and
+// works everywhere, lifter_names only exists when go-sqlite3 is built with
+// the sqlite_fts5 tag and has no postgres equivalent.
+func rebuildSearchIndex(tx *sql.Tx) error {
+	for _, stmt := range []string{`DELETE FROM lifter_trigrams`, `DELETE FROM lifters`} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	rows, err := tx.Query(`SELECT lifter, hometown, COUNT(*) FROM results GROUP BY lifter, hometown`)
+	if err != nil {
+		return err
+	}
+	type entry struct {
+		lifter, hometown string
+		meets            int64
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.lifter, &e.hometown, &e.meets); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	insertLifter, err := tx.Prepare(insertSearchLifter)
+	if err != nil {
+		return err
+	}
+	defer insertLifter.Close()
+	insertTrigram, err := tx.Prepare(`INSERT INTO lifter_trigrams (trigram, lifter_id) VALUES ($1, $2)`)
+	if err != nil {
+		return err
+	}
+	defer insertTrigram.Close()
+
+	for i, e := range entries {
+		if err := indexLifter(insertLifter, insertTrigram, int64(i+1), e.lifter, e.hometown, e.meets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const insertSearchLifter = `INSERT INTO lifters (id, lifter, hometown, search_name, search_hometown, meets) VALUES ($1, $2, $3, $4, $5, $6)`
+
+// indexLifter adds a lifter and the trigrams of their name and hometown to
+// the search index.
+func indexLifter(insertLifter, insertTrigram *sql.Stmt, id int64, lifter, hometown string, meets int64) error {
+	searchName, searchHometown := normalizeName(lifter), normalizeName(hometown)
+	if _, err := insertLifter.Exec(id, lifter, hometown, searchName, searchHometown, meets); err != nil {
+		return err
+	}
+	for _, g := range trigrams(searchName + " " + searchHometown) {
+		if _, err := insertTrigram.Exec(g, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
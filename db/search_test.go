@@ -0,0 +1,121 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeName(t *testing.T) {
+	cases := []struct {
+		in, expected string
+	}{
+		{"D'Angelo Osorio", "dangelo osorio"},
+		{"José  Peña-Núñez", "jose pena nunez"},
+		{"Martha (Mattie) Rogers", "martha mattie rogers"},
+		{"Austin, TX", "austin tx"},
+		{"", ""},
+	}
+	for _, tt := range cases {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.expected, normalizeName(tt.in))
+		})
+	}
+}
+
+func TestTrigrams(t *testing.T) {
+	assert.Equal(t, []string{"  j", " jo", "joe", "oe "}, trigrams("joe"))
+	assert.Equal(t, []string{"  a", " a "}, trigrams("a a"), "trigrams are distinct")
+	assert.Empty(t, trigrams(""))
+}
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("flores", "flores"))
+	assert.Equal(t, 1, levenshtein("fransisco", "francisco"))
+	assert.Equal(t, 3, levenshtein("kitten", "sitting"))
+	assert.Equal(t, 4, levenshtein("", "peña"))
+}
+
+func TestMatchScore(t *testing.T) {
+	assert.Equal(t, 1.0, matchScore("francisco flores", "francisco flores", ""))
+	assert.Equal(t, 1.0, matchScore("flores francisco", "francisco flores", ""), "word order doesn't matter")
+	assert.True(t, matchScore("fransisco flores", "francisco flores", "") > minMatchScore)
+	assert.True(t, matchScore("j bradley", "jessie bradley", "") > minMatchScore)
+	assert.True(t, matchScore("chris austin", "chris wolfe", "austin tx") > minMatchScore)
+	assert.True(t, matchScore("kyle brown", "chris wolfe", "austin tx") < minMatchScore)
+	assert.Equal(t, 0.0, matchScore("", "chris wolfe", ""))
+}
+
+func TestQueryNamesFuzzy(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	for _, q := range []string{"Fransisco Flores", "flores francisco", "Fráncisco Flóres", "FRANCISCO"} {
+		t.Run(q, func(t *testing.T) {
+			r, err := db.QueryNames(q, "1")
+			assert.Nil(t, err)
+			if assert.NotEmpty(t, r.Lifters) {
+				assert.Equal(t, "Francisco Flores", r.Lifters[0].Name)
+				assert.True(t, r.Lifters[0].Score >= minMatchScore)
+			}
+		})
+	}
+
+	// exact matches outrank partial ones
+	r, err := db.QueryNames("steph adams", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Steph Adams", r.Lifters[0].Name)
+	assert.Equal(t, 1.0, r.Lifters[0].Score)
+	for i := 1; i < len(r.Lifters); i++ {
+		assert.True(t, r.Lifters[i-1].Score >= r.Lifters[i].Score, "lifters should be ordered by score")
+	}
+
+	// hometowns are searchable too
+	r, err = db.QueryNames("chris austin", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Chris Wolfe", r.Lifters[0].Name)
+}
+
+func TestQueryNamesScoresEveryCandidate(t *testing.T) {
+	// lifters sharing more of "mos"'s trigrams than Amos does, many more
+	// than a page
+	var results []*Result
+	for i := 0; i < 600; i++ {
+		results = append(results, &Result{
+			Date:     "2018-06-20",
+			MeetName: "Nationals",
+			Lifter:   fmt.Sprintf("Mosby %d", i),
+			Hometown: "Austin, TX",
+			URL:      "http://usaw",
+		})
+	}
+	results = append(results, &Result{Date: "2018-06-20", MeetName: "Nationals", Lifter: "Jack Amos", Hometown: "Reno, NV", URL: "http://usaw"})
+	db, err := NewMemoryDB(results)
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	r, err := db.QueryNames("mos", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(601), r.Total, "every match is counted")
+
+	r, err = db.QueryNames("mos", "13")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(13), r.Current)
+	if assert.Len(t, r.Lifters, 1) {
+		assert.Equal(t, "Jack Amos", r.Lifters[0].Name, "weaker matches are still found")
+	}
+
+	// pages past the end show the last page
+	r, err = db.QueryNames("mos", "99")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(13), r.Current)
+	assert.Equal(t, int64(601), r.Total)
+	assert.Len(t, r.Lifters, 1)
+}
+
+func TestPrefixQuery(t *testing.T) {
+	assert.Equal(t, `"fran"* OR "flo"*`, prefixQuery("fran flo"))
+	assert.Equal(t, "", prefixQuery(""))
+}
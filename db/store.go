@@ -0,0 +1,20 @@
+package db
+
+// Store is the query layer the api is built on. OurDB implements it for
+// sqlite files, in memory fixtures and postgres.
+type Store interface {
+	QueryNames(name, offset string) (*LiftersResponse, error)
+	QueryNamesAfter(name, cursor string) (*LiftersResponse, error)
+	QueryResults(name, hometown string) (*ResultsSummary, error)
+	QueryMeets(f MeetFilter, offset string) (*MeetsResponse, error)
+	QueryMeet(name, date string) (*MeetResults, error)
+	QueryRankings(f RankingFilter, offset string) (*RankingsResponse, error)
+	QueryRecords(f RecordFilter) (*RecordsResponse, error)
+	Suggest(prefix string, limit int) (*SuggestResponse, error)
+	QueryHometowns(name, offset string) (*HometownsResponse, error)
+	QueryHometown(name string) (*HometownResults, error)
+	Ping() error
+	Close()
+}
+
+var _ Store = &OurDB{}
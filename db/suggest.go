@@ -0,0 +1,190 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+// Suggestion is a lifter offered while a name is being typed.
+type Suggestion struct {
+	Name     string `json:"name"`
+	Hometown string `json:"hometown"`
+	Meets    int64  `json:"meets"`
+}
+
+// SuggestResponse holds the suggestions for a partly typed name, best first.
+type SuggestResponse struct {
+	Query       string       `json:"query"`
+	Suggestions []Suggestion `json:"suggestions"`
+}
+
+// rebuildPrefixIndex fills lifter_words with every word of every lifter's
+// search name, so names can be found by the start of any word. It reads the
+// lifters table and has to run after rebuildSearchIndex.
+func rebuildPrefixIndex(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DELETE FROM lifter_words`); err != nil {
+		return err
+	}
+	rows, err := tx.Query(`SELECT id, search_name FROM lifters`)
+	if err != nil {
+		return err
+	}
+	type entry struct {
+		id    int64
+		words []string
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		var searchName string
+		if err := rows.Scan(&e.id, &searchName); err != nil {
+			rows.Close()
+			return err
+		}
+		e.words = strings.Fields(searchName)
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	insert, err := tx.Prepare(`INSERT INTO lifter_words (word, lifter_id) VALUES ($1, $2)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for _, e := range entries {
+		if err := indexWords(insert, e.id, e.words); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexWords adds each distinct word of a lifter's search name to the prefix
+// index.
+func indexWords(insert *sql.Stmt, id int64, words []string) error {
+	seen := map[string]bool{}
+	for _, w := range words {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		if _, err := insert.Exec(w, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefixEnd is the first string after every string starting with prefix,
+// normalized words never hold a 0xff byte.
+func prefixEnd(prefix string) string {
+	return prefix[:len(prefix)-1] + string([]byte{prefix[len(prefix)-1] + 1})
+}
+
+// Suggest returns up to limit lifters with a word of their name starting
+// with each word of prefix, most meets first, so "mat rog" finds Mattie
+// Rogers. Unlike QueryNames there is no typo tolerance, the prefix index
+// keeps it fast enough to run on every key press. Every query word is looked
+// up in the index so common first names are narrowed by the rest.
+func (o *OurDB) Suggest(prefix string, limit int) (*SuggestResponse, error) {
+	logQuery("suggest: %v\n", prefix)
+	resp := &SuggestResponse{Query: prefix, Suggestions: []Suggestion{}}
+	words := strings.Fields(normalizeName(prefix))
+	if len(words) == 0 || limit < 1 {
+		return resp, nil
+	}
+	w := &where{}
+	word := o.dialect.orderBy("word")
+	for _, q := range words {
+		w.add(`l.id IN (SELECT lifter_id FROM lifter_words WHERE `+word+` >= ? AND `+word+` < ?)`, q, prefixEnd(q))
+	}
+	// aliases are resolved by the join and merged as rows are read
+	q := `SELECT COALESCE(a.canonical_lifter, l.lifter), COALESCE(a.canonical_hometown, l.hometown), l.search_name, l.meets
+		FROM lifters l LEFT JOIN lifter_aliases a ON a.lifter = l.lifter AND a.hometown = l.hometown` + w.String() +
+		` ORDER BY l.meets DESC, ` + o.dialect.orderBy("l.lifter") + `, ` + o.dialect.orderBy("l.hometown") +
+		` LIMIT ` + w.next(limit) + ` OFFSET ` + w.next(0)
+
+	// repeated query words are checked as rows are read and merged aliases
+	// share a suggestion, so keep reading until limit is filled
+	found := &suggestions{index: map[identity]int{}}
+	for offset := 0; ; offset += limit {
+		w.args[len(w.args)-1] = offset
+		read, err := found.read(o.db, q, w.args, words)
+		if err != nil {
+			return nil, err
+		}
+		if len(found.list) >= limit || read < limit {
+			break
+		}
+	}
+
+	sort.SliceStable(found.list, func(i, j int) bool {
+		return found.list[i].Meets > found.list[j].Meets
+	})
+	if len(found.list) > limit {
+		found.list = found.list[:limit]
+	}
+	resp.Suggestions = append(resp.Suggestions, found.list...)
+	return resp, nil
+}
+
+// suggestions collects suggestions, folding aliases into their canonical
+// lifter and adding up their meets.
+type suggestions struct {
+	list  []Suggestion
+	index map[identity]int
+}
+
+// read adds the rows of q whose names have a word for every query word. It
+// returns how many rows were read.
+func (s *suggestions) read(db *sql.DB, q string, args []interface{}, words []string) (int, error) {
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	read := 0
+	for rows.Next() {
+		read++
+		var sg Suggestion
+		var searchName string
+		if err := rows.Scan(&sg.Name, &sg.Hometown, &searchName, &sg.Meets); err != nil {
+			return read, err
+		}
+		if !prefixesWords(words, strings.Fields(searchName)) {
+			continue
+		}
+		c := identity{sg.Name, sg.Hometown}
+		if i, ok := s.index[c]; ok {
+			s.list[i].Meets += sg.Meets
+			continue
+		}
+		s.index[c] = len(s.list)
+		s.list = append(s.list, sg)
+	}
+	return read, rows.Err()
+}
+
+// prefixesWords reports whether every query word starts a different name
+// word.
+func prefixesWords(query, name []string) bool {
+	used := make([]bool, len(name))
+	for _, q := range query {
+		matched := false
+		for i, w := range name {
+			if !used[i] && strings.HasPrefix(w, q) {
+				used[i], matched = true, true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func suggestedNames(r *SuggestResponse) []string {
+	var names []string
+	for _, s := range r.Suggestions {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestSuggest(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	r, err := db.Suggest("wol", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []Suggestion{{Name: "Chris Wolfe", Hometown: "Austin, TX", Meets: 3}}, r.Suggestions)
+
+	// any word can start the match and word order doesn't matter
+	r, err = db.Suggest("rog mat", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Mattie Rogers"}, suggestedNames(r))
+
+	r, err = db.Suggest("Mo", 10)
+	assert.Nil(t, err)
+	assert.Contains(t, suggestedNames(r), "Jessica Moses")
+	assert.Contains(t, suggestedNames(r), "Stephanie Moore")
+	assert.NotContains(t, suggestedNames(r), "Amos Turner", "only prefixes match")
+
+	r, err = db.Suggest("steph moore", 10)
+	assert.Nil(t, err)
+	assert.Len(t, r.Suggestions, 4)
+
+	r, err = db.Suggest("steph", 3)
+	assert.Nil(t, err)
+	assert.Len(t, r.Suggestions, 3)
+
+	// both words have to match different words of the name
+	r, err = db.Suggest("moore moore", 10)
+	assert.Nil(t, err)
+	assert.Empty(t, r.Suggestions)
+
+	r, err = db.Suggest("  ", 10)
+	assert.Nil(t, err)
+	assert.NotNil(t, r.Suggestions, "empty results encode as []")
+	assert.Empty(t, r.Suggestions)
+}
+
+func TestSuggestMergesAliases(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	r, err := db.Suggest("kyle bro", 10)
+	assert.Nil(t, err)
+	assert.Len(t, r.Suggestions, 2)
+
+	reno := Lifter{Name: "Kyle Brown", Hometown: "Reno, NV"}
+	portland := Lifter{Name: "Kyle Brown", Hometown: "Portland, OR"}
+	assert.Nil(t, db.MergeLifters(reno, portland))
+
+	r, err = db.Suggest("kyle bro", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []Suggestion{{Name: "Kyle Brown", Hometown: "Portland, OR", Meets: 2}}, r.Suggestions)
+}
+
+func TestSuggestFillsLimit(t *testing.T) {
+	// the lifters with the most meets only have one word starting with "mo"
+	var results []*Result
+	for i, l := range []struct {
+		name  string
+		meets int
+	}{{"Ann Moore", 5}, {"Bea Moore", 5}, {"Cal Moore", 5}, {"Mo Moore", 1}} {
+		for m := 0; m < l.meets; m++ {
+			results = append(results, &Result{
+				Date:     fmt.Sprintf("2018-01-%02d", m+1),
+				MeetName: fmt.Sprintf("Meet %d", m),
+				Lifter:   l.name,
+				Hometown: fmt.Sprintf("Town %d", i),
+				URL:      fmt.Sprintf("https://example.com/meet/%d", m),
+			})
+		}
+	}
+	db, err := NewMemoryDB(results)
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	r, err := db.Suggest("mo mo", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Mo Moore"}, suggestedNames(r))
+}
+
+var suggestResponse *SuggestResponse
+
+func BenchmarkSuggest(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	db, err := syntheticDB(20000, 5)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// a first name and the start of a last name, as typed
+		name := strings.Fields(syntheticLifter(i % 20000))
+		r, err := db.Suggest(name[0]+" "+name[1][:3], defaultBenchSuggestions)
+		if err != nil {
+			b.Fatal(err)
+		}
+		suggestResponse = r
+	}
+}
+
+const defaultBenchSuggestions = 8
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"gitlab.com/derwolfe/faststats/scoring"
+)
+
+const insertResult = `INSERT INTO results (date, meet_name, lifter, weight_class, competition_weight, hometown, cj1, cj2, cj3, sn1, sn2, sn3, total, best_snatch, best_cleanjerk, url, sinclair)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`
+
+const updateLifts = `
+		weight_class = excluded.weight_class,
+		competition_weight = excluded.competition_weight,
+		cj1 = excluded.cj1,
+		cj2 = excluded.cj2,
+		cj3 = excluded.cj3,
+		sn1 = excluded.sn1,
+		sn2 = excluded.sn2,
+		sn3 = excluded.sn3,
+		total = excluded.total,
+		best_snatch = excluded.best_snatch,
+		best_cleanjerk = excluded.best_cleanjerk,
+		sinclair = excluded.sinclair`
+
+// upsertResult replaces a result from the same meet url, upsertUnlinkedResult
+// one without a url from the same meet name and date.
+const (
+	upsertResult = insertResult + `
+	ON CONFLICT (url, lifter, hometown) WHERE url <> '' DO UPDATE SET
+		date = excluded.date,
+		meet_name = excluded.meet_name,` + updateLifts
+	upsertUnlinkedResult = insertResult + `
+	ON CONFLICT (meet_name, date, lifter, hometown) WHERE url = '' DO UPDATE SET` + updateLifts
+)
+
+// UpsertResults inserts results in a single transaction, replacing any row
+// with the same url, lifter and hometown so imports can be re-run. Results
+// without a url replace the row from the same meet name and date instead.
+// Sinclair scores are stored for ranking and the name search indexes are
+// updated in the same transaction.
+func (o *OurDB) UpsertResults(results []*Result) error {
+	tx, err := o.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(upsertResult)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	unlinked, err := tx.Prepare(upsertUnlinkedResult)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer unlinked.Close()
+
+	for _, r := range results {
+		upsert := stmt
+		if r.URL == "" {
+			upsert = unlinked
+		}
+		sinclair := scoring.Sinclair(r.Total, r.CompetitionWeight, r.Date, r.Weightclass)
+		_, err := upsert.Exec(r.Date, r.MeetName, r.Lifter, r.Weightclass, r.CompetitionWeight, r.Hometown, r.CJ1, r.CJ2, r.CJ3, r.SN1, r.SN2, r.SN3, r.Total, r.BestSN, r.BestCJ, r.URL, sinclair)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("upserting %v at %v: %v", r.Lifter, r.MeetName, err)
+		}
+	}
+	if err := updateSearchIndexes(tx, results); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("updating search indexes: %v", err)
+	}
+	return tx.Commit()
+}
+
+// updateSearchIndexes brings the lifters, lifter_trigrams and lifter_words
+// tables up to date for the lifters in results only. Upserts never remove a
+// lifter or change their search name, so known lifters just get a new meet
+// count and only new lifters are indexed.
+func updateSearchIndexes(tx *sql.Tx, results []*Result) error {
+	var nextID int64
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM lifters`).Scan(&nextID); err != nil {
+		return err
+	}
+
+	findLifter, err := tx.Prepare(`SELECT id FROM lifters WHERE lifter = $1 AND hometown = $2`)
+	if err != nil {
+		return err
+	}
+	defer findLifter.Close()
+	countMeets, err := tx.Prepare(`SELECT COUNT(*) FROM results WHERE lifter = $1 AND hometown = $2`)
+	if err != nil {
+		return err
+	}
+	defer countMeets.Close()
+	updateMeets, err := tx.Prepare(`UPDATE lifters SET meets = $1 WHERE id = $2`)
+	if err != nil {
+		return err
+	}
+	defer updateMeets.Close()
+	insertLifter, err := tx.Prepare(insertSearchLifter)
+	if err != nil {
+		return err
+	}
+	defer insertLifter.Close()
+	insertTrigram, err := tx.Prepare(`INSERT INTO lifter_trigrams (trigram, lifter_id) VALUES ($1, $2)`)
+	if err != nil {
+		return err
+	}
+	defer insertTrigram.Close()
+	insertWord, err := tx.Prepare(`INSERT INTO lifter_words (word, lifter_id) VALUES ($1, $2)`)
+	if err != nil {
+		return err
+	}
+	defer insertWord.Close()
+
+	seen := map[identity]bool{}
+	for _, r := range results {
+		id := identity{r.Lifter, r.Hometown}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		var meets int64
+		if err := countMeets.QueryRow(id.lifter, id.hometown).Scan(&meets); err != nil {
+			return err
+		}
+		var lifterID int64
+		err := findLifter.QueryRow(id.lifter, id.hometown).Scan(&lifterID)
+		switch {
+		case err == nil:
+			if _, err := updateMeets.Exec(meets, lifterID); err != nil {
+				return err
+			}
+			continue
+		case err != sql.ErrNoRows:
+			return err
+		}
+
+		nextID++
+		if err := indexLifter(insertLifter, insertTrigram, nextID, id.lifter, id.hometown, meets); err != nil {
+			return err
+		}
+		if err := indexWords(insertWord, nextID, strings.Fields(normalizeName(id.lifter))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CountResults returns the number of rows in the results table.
+func (o *OurDB) CountResults() (int64, error) {
+	var total int64
+	err := o.db.QueryRow(`SELECT COUNT(*) FROM results`).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// searchIndexSnapshot lists every lifter in the search indexes with their
+// meet count, trigrams and words, leaving out ids.
+func searchIndexSnapshot(t *testing.T, o *OurDB) []string {
+	rows, err := o.db.Query(`SELECT l.lifter, l.hometown, l.search_name, l.meets, 'trigram', t.trigram FROM lifters l JOIN lifter_trigrams t ON t.lifter_id = l.id
+		UNION ALL SELECT l.lifter, l.hometown, l.search_name, l.meets, 'word', w.word FROM lifters l JOIN lifter_words w ON w.lifter_id = l.id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var snapshot []string
+	for rows.Next() {
+		var lifter, hometown, searchName, kind, value string
+		var meets int64
+		if err := rows.Scan(&lifter, &hometown, &searchName, &meets, &kind, &value); err != nil {
+			t.Fatal(err)
+		}
+		snapshot = append(snapshot, fmt.Sprintf("%v|%v|%v|%d|%v|%v", lifter, hometown, searchName, meets, kind, value))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(snapshot)
+	return snapshot
+}
+
+func TestUpsertResultsUpdatesSearchIndexes(t *testing.T) {
+	o, err := syntheticDB(20, 2)
+	assert.Nil(t, err, "failed to build db")
+	defer o.Close()
+
+	// a known lifter at a new meet and a lifter the index hasn't seen yet
+	known, err := o.QueryResults(syntheticLifter(3), "Town 3")
+	assert.Nil(t, err)
+	added := *known.Results[0]
+	added.URL = "https://example.com/meet/new"
+	added.MeetName = "New Meet"
+	newcomer := added
+	newcomer.Lifter = "José Peña-Núñez"
+	newcomer.Hometown = "Austin, TX"
+	assert.Nil(t, o.UpsertResults([]*Result{&added, &newcomer, &newcomer}))
+
+	updated := searchIndexSnapshot(t, o)
+	tx, err := o.db.Begin()
+	assert.Nil(t, err)
+	assert.Nil(t, rebuildSearchIndex(tx))
+	assert.Nil(t, rebuildPrefixIndex(tx))
+	assert.Nil(t, tx.Commit())
+	assert.Equal(t, searchIndexSnapshot(t, o), updated, "updating should match a rebuild")
+
+	r, err := o.QueryNames("jose pena", "1")
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, r.Lifters) {
+		assert.Equal(t, "José Peña-Núñez", r.Lifters[0].Name)
+	}
+	s, err := o.Suggest("nun", 5)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, s.Suggestions)
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+)
+
+// Flag names a data problem found in a result. Flags are informational, the
+// result is still shown as USAW published it.
+type Flag string
+
+const (
+	FlagTotalMismatch     Flag = "total_mismatch"
+	FlagBestSnatch        Flag = "best_snatch_mismatch"
+	FlagBestCleanJerk     Flag = "best_cleanjerk_mismatch"
+	FlagSnatchOverCJ      Flag = "snatch_over_cleanjerk"
+	FlagOutsideClass      Flag = "bodyweight_outside_class"
+	FlagSnatchAttempts    Flag = "snatch_attempts_not_increasing"
+	FlagCleanJerkAttempts Flag = "cleanjerk_attempts_not_increasing"
+)
+
+// Flags lists every flag in the order they are reported.
+var Flags = []Flag{
+	FlagTotalMismatch,
+	FlagBestSnatch,
+	FlagBestCleanJerk,
+	FlagSnatchOverCJ,
+	FlagOutsideClass,
+	FlagSnatchAttempts,
+	FlagCleanJerkAttempts,
+}
+
+var flagDescriptions = map[Flag]string{
+	FlagTotalMismatch:     "total isn't best snatch + best clean & jerk",
+	FlagBestSnatch:        "best snatch isn't the heaviest made snatch",
+	FlagBestCleanJerk:     "best clean & jerk isn't the heaviest made clean & jerk",
+	FlagSnatchOverCJ:      "best snatch is heavier than best clean & jerk",
+	FlagOutsideClass:      "bodyweight is outside the weight class",
+	FlagSnatchAttempts:    "snatch attempts don't increase",
+	FlagCleanJerkAttempts: "clean & jerk attempts don't increase",
+}
+
+// Description explains the flag for people.
+func (f Flag) Description() string {
+	return flagDescriptions[f]
+}
+
+// bestAttempt is the heaviest made attempt, misses are stored negative.
+func bestAttempt(attempts ...decimal.Decimal) decimal.Decimal {
+	best := decimal.Zero
+	for _, a := range attempts {
+		best = maxDec(best, a)
+	}
+	return best
+}
+
+// attemptsIncrease reports whether attempts follow the rules: the bar never
+// gets lighter, and goes up after a make. Attempts not taken are zero.
+func attemptsIncrease(attempts ...decimal.Decimal) bool {
+	var prev decimal.Decimal
+	for _, a := range attempts {
+		if a.IsZero() {
+			continue
+		}
+		if !prev.IsZero() {
+			if a.Abs().LessThan(prev.Abs()) {
+				return false
+			}
+			if prev.Sign() > 0 && a.Abs().Equal(prev) {
+				return false
+			}
+		}
+		prev = a
+	}
+	return true
+}
+
+// inClass reports whether bodyweight is allowed in weightClass. Only the
+// upper limit of a class is checked, plus the floor of unlimited classes,
+// because the classes below depend on the era.
+func inClass(bodyweight decimal.Decimal, weightClass string) bool {
+	if bodyweight.Sign() <= 0 {
+		return true
+	}
+	m := classLimitReg.FindStringSubmatch(weightClass)
+	if m == nil {
+		return true
+	}
+	limit, err := strconv.ParseFloat(m[2], 64)
+	if err != nil {
+		return true
+	}
+	d := decimal.NewFromFloat(limit)
+	if m[1] != "" || m[4] != "" {
+		return bodyweight.GreaterThan(d)
+	}
+	return !bodyweight.GreaterThan(d)
+}
+
+// Validate checks a result for impossible or inconsistent values.
+func (r *Result) Validate() []Flag {
+	var flags []Flag
+	want := decimal.Zero
+	if r.BestSN.Sign() > 0 && r.BestCJ.Sign() > 0 {
+		want = r.BestSN.Add(r.BestCJ)
+	}
+	if !r.Total.Equal(want) {
+		flags = append(flags, FlagTotalMismatch)
+	}
+	if !r.BestSN.Equal(bestAttempt(r.SN1, r.SN2, r.SN3)) {
+		flags = append(flags, FlagBestSnatch)
+	}
+	if !r.BestCJ.Equal(bestAttempt(r.CJ1, r.CJ2, r.CJ3)) {
+		flags = append(flags, FlagBestCleanJerk)
+	}
+	if r.BestCJ.Sign() > 0 && r.BestSN.GreaterThan(r.BestCJ) {
+		flags = append(flags, FlagSnatchOverCJ)
+	}
+	if !inClass(r.CompetitionWeight, r.Weightclass) {
+		flags = append(flags, FlagOutsideClass)
+	}
+	if !attemptsIncrease(r.SN1, r.SN2, r.SN3) {
+		flags = append(flags, FlagSnatchAttempts)
+	}
+	if !attemptsIncrease(r.CJ1, r.CJ2, r.CJ3) {
+		flags = append(flags, FlagCleanJerkAttempts)
+	}
+	return flags
+}
+
+// ValidationReport summarises the flags raised across a whole database.
+type ValidationReport struct {
+	Checked int64
+	Flagged int64
+	Counts  map[Flag]int64
+	// Examples holds up to the requested number of flagged results per flag
+	Examples map[Flag][]*Result
+}
+
+// Validate checks every result in the database, keeping up to examples
+// flagged results for each flag.
+func (o *OurDB) Validate(examples int) (*ValidationReport, error) {
+	rows, err := o.db.Query(`SELECT date, meet_name, lifter, weight_class, competition_weight, hometown, cj1, cj2, cj3, sn1, sn2, sn3, total, best_snatch, best_cleanjerk, url FROM results`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &ValidationReport{Counts: map[Flag]int64{}, Examples: map[Flag][]*Result{}}
+	for rows.Next() {
+		r := &Result{}
+		err = rows.Scan(&r.Date, &r.MeetName, &r.Lifter, &r.Weightclass, &r.CompetitionWeight, &r.Hometown, &r.CJ1, &r.CJ2, &r.CJ3, &r.SN1, &r.SN2, &r.SN3, &r.Total, &r.BestSN, &r.BestCJ, &r.URL)
+		if err != nil {
+			return nil, err
+		}
+		report.Checked++
+		r.Flags = r.Validate()
+		if len(r.Flags) > 0 {
+			report.Flagged++
+		}
+		for _, f := range r.Flags {
+			report.Counts[f]++
+			if len(report.Examples[f]) < examples {
+				report.Examples[f] = append(report.Examples[f], r)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, ex := range report.Examples {
+		sort.Slice(ex, func(i, j int) bool { return ex[i].Date > ex[j].Date })
+	}
+	log.Printf("validated %d results, %d flagged\n", report.Checked, report.Flagged)
+	return report, nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func lifts(ls ...string) []decimal.Decimal {
+	d := make([]decimal.Decimal, len(ls))
+	for i, l := range ls {
+		d[i] = decimal.RequireFromString(l)
+	}
+	return d
+}
+
+func TestAttemptsIncrease(t *testing.T) {
+	assert.True(t, attemptsIncrease(lifts("80", "-85", "85")...))
+	assert.True(t, attemptsIncrease(lifts("-80", "-80", "-80")...), "a miss can be repeated")
+	assert.True(t, attemptsIncrease(lifts("80", "0", "85")...), "passed attempts are skipped")
+	assert.False(t, attemptsIncrease(lifts("85", "80", "90")...))
+	assert.False(t, attemptsIncrease(lifts("80", "80", "85")...), "a make must be followed by a heavier attempt")
+}
+
+func TestInClass(t *testing.T) {
+	assert.True(t, inClass(decimal.RequireFromString("76.4"), "Men's 77Kg"))
+	assert.False(t, inClass(decimal.RequireFromString("77.2"), "Men's 77Kg"))
+	assert.True(t, inClass(decimal.RequireFromString("120"), "Men's +105Kg"))
+	assert.False(t, inClass(decimal.RequireFromString("101"), "Men's +105Kg"))
+	assert.True(t, inClass(decimal.Zero, "Men's 77Kg"), "missing bodyweights aren't flagged")
+}
+
+func TestValidate(t *testing.T) {
+	r := &Result{
+		Weightclass:       "Men's 77Kg",
+		CompetitionWeight: decimal.RequireFromString("76.4"),
+		SN1:               decimal.RequireFromString("75"),
+		SN2:               decimal.RequireFromString("80"),
+		SN3:               decimal.RequireFromString("-85"),
+		CJ1:               decimal.RequireFromString("95"),
+		CJ2:               decimal.RequireFromString("100"),
+		CJ3:               decimal.RequireFromString("105"),
+		BestSN:            decimal.RequireFromString("80"),
+		BestCJ:            decimal.RequireFromString("105"),
+		Total:             decimal.RequireFromString("185"),
+	}
+	assert.Empty(t, r.Validate())
+
+	r.Total = decimal.RequireFromString("190")
+	r.BestSN = decimal.RequireFromString("110")
+	r.CompetitionWeight = decimal.RequireFromString("80")
+	assert.Equal(t, []Flag{FlagTotalMismatch, FlagBestSnatch, FlagSnatchOverCJ, FlagOutsideClass}, r.Validate())
+
+	bomb := &Result{SN1: decimal.RequireFromString("-100"), CJ1: decimal.RequireFromString("120"), BestCJ: decimal.RequireFromString("120")}
+	assert.Empty(t, bomb.Validate(), "a bomb out has no total")
+}
+
+func TestValidateDB(t *testing.T) {
+	db, err := LoadFixtures("testdata/fixtures.jsonl")
+	assert.Nil(t, err, "failed to build db")
+	defer db.Close()
+
+	report, err := db.Validate(3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(72), report.Checked)
+	assert.Equal(t, int64(0), report.Flagged)
+}
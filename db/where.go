@@ -0,0 +1,38 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// where builds a WHERE clause from optional filters, numbering the
+// placeholders as it goes.
+type where struct {
+	clauses []string
+	args    []interface{}
+}
+
+// add appends a clause; every ? in clause is replaced by the next numbered
+// placeholder and consumes one of args.
+func (w *where) add(clause string, args ...interface{}) {
+	for _, a := range args {
+		w.args = append(w.args, a)
+		clause = strings.Replace(clause, "?", fmt.Sprintf("$%d", len(w.args)), 1)
+	}
+	w.clauses = append(w.clauses, clause)
+}
+
+// next returns the placeholder for an extra argument such as a LIMIT.
+// Placeholders have to appear in the query in the order they were made,
+// sqlite treats $1 as a name and numbers parameters as it meets them.
+func (w *where) next(arg interface{}) string {
+	w.args = append(w.args, arg)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+func (w *where) String() string {
+	if len(w.clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.clauses, " AND ")
+}
@@ -0,0 +1,313 @@
+// Package importer reads USAW result exports so they can be loaded into the
+// results database.
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+// field names used internally, each export column is mapped onto one of these
+const (
+	fDate        = "date"
+	fMeet        = "meet_name"
+	fLifter      = "lifter"
+	fWeightclass = "weight_class"
+	fBodyweight  = "competition_weight"
+	fHometown    = "hometown"
+	fCJ1         = "cj1"
+	fCJ2         = "cj2"
+	fCJ3         = "cj3"
+	fSN1         = "sn1"
+	fSN2         = "sn2"
+	fSN3         = "sn3"
+	fTotal       = "total"
+	fBestSN      = "best_snatch"
+	fBestCJ      = "best_cleanjerk"
+	fURL         = "url"
+)
+
+// aliases maps the column names seen in USAW exports to our field names.
+// Keys are normalized with normalizeHeader.
+var aliases = map[string]string{
+	"date":               fDate,
+	"meet date":          fDate,
+	"meet":               fMeet,
+	"meet name":          fMeet,
+	"meet_name":          fMeet,
+	"lifter":             fLifter,
+	"name":               fLifter,
+	"athlete":            fLifter,
+	"lifter name":        fLifter,
+	"weight class":       fWeightclass,
+	"weight_class":       fWeightclass,
+	"class":              fWeightclass,
+	"body weight":        fBodyweight,
+	"body weight (kg)":   fBodyweight,
+	"bodyweight":         fBodyweight,
+	"competition weight": fBodyweight,
+	"competition_weight": fBodyweight,
+	"hometown":           fHometown,
+	"club":               fHometown,
+	"cj1":                fCJ1,
+	"cj2":                fCJ2,
+	"cj3":                fCJ3,
+	"c&j lift 1":         fCJ1,
+	"c&j lift 2":         fCJ2,
+	"c&j lift 3":         fCJ3,
+	"cleanjerk1":         fCJ1,
+	"cleanjerk2":         fCJ2,
+	"cleanjerk3":         fCJ3,
+	"sn1":                fSN1,
+	"sn2":                fSN2,
+	"sn3":                fSN3,
+	"snatch lift 1":      fSN1,
+	"snatch lift 2":      fSN2,
+	"snatch lift 3":      fSN3,
+	"snatch1":            fSN1,
+	"snatch2":            fSN2,
+	"snatch3":            fSN3,
+	"total":              fTotal,
+	"best snatch":        fBestSN,
+	"best_snatch":        fBestSN,
+	"best sn":            fBestSN,
+	"best c&j":           fBestCJ,
+	"best cj":            fBestCJ,
+	"best_cj":            fBestCJ,
+	"best_cleanjerk":     fBestCJ,
+	"best clean & jerk":  fBestCJ,
+	"url":                fURL,
+	"link":               fURL,
+	"meet url":           fURL,
+}
+
+func normalizeHeader(h string) string {
+	h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+	return strings.Join(strings.Fields(h), " ")
+}
+
+// ReadFile parses a .csv or .jsonl export.
+func ReadFile(path string) ([]*db.Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ReadCSV(f)
+	case ".jsonl", ".json", ".ndjson":
+		return ReadJSONL(f)
+	}
+	return nil, fmt.Errorf("%v: unsupported file type, expected .csv or .jsonl", path)
+}
+
+// ReadCSV parses a CSV export with a header row.
+func ReadCSV(r io.Reader) ([]*db.Result, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %v", err)
+	}
+	columns := make([]string, len(header))
+	for i, h := range header {
+		columns[i] = aliases[normalizeHeader(h)]
+	}
+
+	var results []*db.Result
+	line := 1
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		row := map[string]string{}
+		for i, v := range record {
+			if i < len(columns) && columns[i] != "" {
+				row[columns[i]] = v
+			}
+		}
+		res, err := toResult(row)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// ReadJSONL parses newline delimited JSON objects, one result per line.
+func ReadJSONL(r io.Reader) ([]*db.Result, error) {
+	var results []*db.Result
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for s.Scan() {
+		line++
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			continue
+		}
+		var raw map[string]interface{}
+		d := json.NewDecoder(strings.NewReader(text))
+		d.UseNumber()
+		if err := d.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		row := map[string]string{}
+		for k, v := range raw {
+			field := aliases[normalizeHeader(k)]
+			if field == "" || v == nil {
+				continue
+			}
+			row[field] = fmt.Sprint(v)
+		}
+		res, err := toResult(row)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		results = append(results, res)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func toResult(row map[string]string) (*db.Result, error) {
+	r := &db.Result{
+		MeetName:    strings.TrimSpace(row[fMeet]),
+		Lifter:      strings.Join(strings.Fields(row[fLifter]), " "),
+		Weightclass: strings.TrimSpace(row[fWeightclass]),
+		Hometown:    strings.TrimSpace(row[fHometown]),
+		URL:         strings.TrimSpace(row[fURL]),
+	}
+	if r.Lifter == "" {
+		return nil, fmt.Errorf("missing lifter")
+	}
+	if r.MeetName == "" {
+		return nil, fmt.Errorf("missing meet name for %v", r.Lifter)
+	}
+	date, err := NormalizeDate(row[fDate])
+	if err != nil {
+		return nil, err
+	}
+	r.Date = date
+
+	for _, d := range []struct {
+		field string
+		dest  *decimal.Decimal
+	}{
+		{fBodyweight, &r.CompetitionWeight},
+		{fCJ1, &r.CJ1}, {fCJ2, &r.CJ2}, {fCJ3, &r.CJ3},
+		{fSN1, &r.SN1}, {fSN2, &r.SN2}, {fSN3, &r.SN3},
+		{fTotal, &r.Total}, {fBestSN, &r.BestSN}, {fBestCJ, &r.BestCJ},
+	} {
+		v, err := NormalizeDecimal(row[d.field])
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", d.field, err)
+		}
+		*d.dest = v
+	}
+
+	// older exports leave the bests blank, they can be derived from the attempts
+	if _, ok := row[fBestSN]; !ok || r.BestSN.IsZero() {
+		r.BestSN = bestOf(r.SN1, r.SN2, r.SN3)
+	}
+	if _, ok := row[fBestCJ]; !ok || r.BestCJ.IsZero() {
+		r.BestCJ = bestOf(r.CJ1, r.CJ2, r.CJ3)
+	}
+	if _, ok := row[fTotal]; !ok && r.BestSN.Sign() > 0 && r.BestCJ.Sign() > 0 {
+		r.Total = r.BestSN.Add(r.BestCJ)
+	}
+	return r, nil
+}
+
+// bestOf returns the heaviest made lift, misses are negative.
+func bestOf(attempts ...decimal.Decimal) decimal.Decimal {
+	best := decimal.Zero
+	for _, a := range attempts {
+		if a.GreaterThan(best) {
+			best = a
+		}
+	}
+	return best
+}
+
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"1/2/2006",
+	"01/02/2006",
+	"1/2/06",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"2-Jan-06",
+	"2-Jan-2006",
+	"2006/01/02",
+}
+
+// NormalizeDate converts the date formats found in USAW exports to YYYY-MM-DD.
+func NormalizeDate(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	return "", fmt.Errorf("unrecognized date %q", s)
+}
+
+// NormalizeDecimal parses a weight, treating blanks and dashes as zero. A
+// trailing kg unit is ignored.
+func NormalizeDecimal(s string) (decimal.Decimal, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	s = strings.TrimSpace(strings.TrimSuffix(s, "kg"))
+	s = strings.Replace(s, ",", ".", 1)
+	switch s {
+	case "", "-", "--", "---", "dns", "n/a":
+		return decimal.Zero, nil
+	}
+	return decimal.NewFromString(s)
+}
+
+// Import applies any pending migrations and upserts every result in paths.
+// It returns the number of rows read.
+func Import(o *db.OurDB, paths ...string) (int, error) {
+	if _, err := o.Migrate(); err != nil {
+		return 0, err
+	}
+	read := 0
+	for _, p := range paths {
+		results, err := ReadFile(p)
+		if err != nil {
+			return read, fmt.Errorf("%v: %v", p, err)
+		}
+		if err := o.UpsertResults(results); err != nil {
+			return read, fmt.Errorf("%v: %v", p, err)
+		}
+		read += len(results)
+		log.Printf("imported %d results from %v\n", len(results), p)
+	}
+	return read, nil
+}
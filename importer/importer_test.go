@@ -0,0 +1,169 @@
+package importer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+func TestNormalizeDate(t *testing.T) {
+	cases := []struct {
+		in, expected string
+	}{
+		{"2018-06-20", "2018-06-20"},
+		{"6/20/2018", "2018-06-20"},
+		{"06/20/2018", "2018-06-20"},
+		{"6/20/18", "2018-06-20"},
+		{"Jun 20, 2018", "2018-06-20"},
+		{"20-Jun-18", "2018-06-20"},
+		{" 2018-06-20T00:00:00Z ", "2018-06-20"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.in, func(t *testing.T) {
+			actual, err := NormalizeDate(tt.in)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+	_, err := NormalizeDate("sometime in june")
+	assert.NotNil(t, err)
+}
+
+func TestNormalizeDecimal(t *testing.T) {
+	cases := []struct {
+		in, expected string
+	}{
+		{"105", "105"},
+		{"-105", "-105"},
+		{"76.50 kg", "76.5"},
+		{"76,5", "76.5"},
+		{"", "0"},
+		{"-", "0"},
+		{"DNS", "0"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.in, func(t *testing.T) {
+			actual, err := NormalizeDecimal(tt.in)
+			assert.Nil(t, err)
+			assert.True(t, decimal.RequireFromString(tt.expected).Equal(actual), "expected %v got %v", tt.expected, actual)
+		})
+	}
+	_, err := NormalizeDecimal("heavy")
+	assert.NotNil(t, err)
+}
+
+func TestReadCSV(t *testing.T) {
+	results, err := ReadFile("testdata/results.csv")
+	assert.Nil(t, err)
+	assert.Len(t, results, 3)
+
+	r := results[0]
+	assert.Equal(t, "2018-06-20", r.Date)
+	assert.Equal(t, "Chris Wolfe", r.Lifter, "whitespace in names is collapsed")
+	assert.Equal(t, "Austin, TX", r.Hometown)
+	assert.Equal(t, "76.5", r.CompetitionWeight.String())
+	assert.Equal(t, "-88", r.SN3.String())
+
+	// blank bests are derived from the attempts
+	assert.Equal(t, "73", results[1].BestSN.String())
+	assert.Equal(t, "93", results[1].BestCJ.String())
+
+	assert.Equal(t, "2017-03-03", results[2].Date)
+	assert.True(t, results[2].SN1.IsZero())
+}
+
+func TestReadCSVAgeCategory(t *testing.T) {
+	csv := "Meet,Date,Lifter,Weight Class,Age Category,Hometown,Total\n" +
+		"Nationals,2018-06-20,Chris Wolfe,Men's 81Kg,Masters 35,\"Austin, TX\",192\n"
+	results, err := ReadCSV(strings.NewReader(csv))
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "Men's 81Kg", results[0].Weightclass, "age categories aren't weight classes")
+}
+
+func TestReadJSONL(t *testing.T) {
+	results, err := ReadFile("testdata/results.jsonl")
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "106", results[0].BestCJ.String())
+	assert.Equal(t, "85", results[0].BestSN.String())
+	assert.Equal(t, "285", results[1].Total.String())
+}
+
+func TestReadErrors(t *testing.T) {
+	_, err := ReadCSV(strings.NewReader("lifter,date\n,2018-01-01\n"))
+	assert.NotNil(t, err, "missing lifter should fail")
+
+	_, err = ReadJSONL(strings.NewReader(`{"lifter": "a", "meet": "b", "date": "never"}`))
+	assert.NotNil(t, err, "bad date should fail")
+
+	_, err = ReadFile("testdata/results.xlsx")
+	assert.NotNil(t, err)
+}
+
+func TestImportIdempotent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "faststats")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	o, err := db.OpenDB(filepath.Join(dir, "results.db"))
+	assert.Nil(t, err)
+	defer o.Close()
+
+	read, err := Import(o, "testdata/results.csv", "testdata/results.jsonl")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, read)
+
+	// the jsonl file updates chris' first result rather than duplicating it
+	total, err := o.CountResults()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), total)
+
+	_, err = Import(o, "testdata/results.csv", "testdata/results.jsonl")
+	assert.Nil(t, err)
+	total, err = o.CountResults()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), total)
+
+	assert.Nil(t, o.CheckSchema(), "import should leave a servable database")
+
+	summary, err := o.QueryResults("Chris Wolfe", "Austin, TX")
+	assert.Nil(t, err)
+	assert.Len(t, summary.Results, 2)
+	assert.Equal(t, "191", summary.BestTotal.String())
+}
+
+func TestImportWithoutURL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "faststats")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "results.csv")
+	csv := "Meet,Date,Lifter,Weight Class,Hometown,Total\n" +
+		"Nationals,2018-06-20,Chris Wolfe,Men's 81Kg,\"Austin, TX\",192\n" +
+		"Texas State,2018-03-03,Chris Wolfe,Men's 81Kg,\"Austin, TX\",185\n"
+	assert.Nil(t, ioutil.WriteFile(path, []byte(csv), 0644))
+
+	o, err := db.OpenDB(filepath.Join(dir, "results.db"))
+	assert.Nil(t, err)
+	defer o.Close()
+
+	// twice, re-importing still replaces rows from the same meet
+	for i := 0; i < 2; i++ {
+		_, err = Import(o, path)
+		assert.Nil(t, err)
+	}
+	total, err := o.CountResults()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total, "meets without a url are told apart by name and date")
+
+	summary, err := o.QueryResults("Chris Wolfe", "Austin, TX")
+	assert.Nil(t, err)
+	assert.Len(t, summary.Results, 2)
+}
@@ -1,36 +1,273 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"gitlab.com/derwolfe/faststats/api"
 	"gitlab.com/derwolfe/faststats/db"
+	"gitlab.com/derwolfe/faststats/importer"
+	"gitlab.com/derwolfe/faststats/server"
+	"io"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
 func main() {
-	// open the DB in read only mode. If we get SQLi this should limit damage.
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if len(os.Args) > 1 {
+		// subcommands return their errors rather than exiting so that their
+		// deferred closes run first
+		var err error
+		switch os.Args[1] {
+		case "import":
+			err = runImport(os.Args[2:])
+		case "migrate":
+			err = runMigrate(os.Args[2:])
+		case "lifters":
+			err = runLifters(os.Args[2:])
+		case "validate":
+			err = runValidate(os.Args[2:])
+		case "serve":
+			serve(os.Args[2:])
+			return
+		default:
+			fmt.Fprintf(os.Stderr, "usage: %s [serve [-config faststats.json] [-addr :8080] [-db results.db] [-log-level info] | import -db results.db FILE... | migrate -db results.db [-out copy.db] [-status] | lifters -db results.db [-merge ALIAS -into CANONICAL | -split ALIAS] | validate -db results.db [-examples 5]]\n", os.Args[0])
+			os.Exit(2)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	log.Printf("Starting on %v\n", port)
+	serve(nil)
+}
 
-	db, err := db.BuildDB("./results.db?_query_only=1")
+// serve runs the website until it receives SIGTERM or an interrupt, then
+// lets in flight requests finish.
+func serve(args []string) {
+	cfg, err := server.LoadConfig(args, os.Getenv)
+	if err == flag.ErrHelp {
+		os.Exit(2)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-	api := api.NewAPI(db)
+	store, err := server.OpenStore(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	if err := server.New(cfg, store).Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runImport builds or refreshes a results database from USAW CSV/JSONL exports.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dbPath := fs.String("db", "./results.db", "sqlite database or postgres:// URL to create or update")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s import [-db results.db] FILE...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	o, err := db.OpenDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+
+	read, err := importer.Import(o, fs.Args()...)
+	if err != nil {
+		return err
+	}
+	total, err := o.CountResults()
+	if err != nil {
+		return err
+	}
+	log.Printf("imported %d results, %v now holds %d results\n", read, *dbPath, total)
+	return nil
+}
+
+// runMigrate applies pending schema migrations. The server opens the database
+// read only, so migrations are normally applied to a copy which is then
+// swapped in.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbPath := fs.String("db", "./results.db", "sqlite database or postgres:// URL to migrate")
+	out := fs.String("out", "", "copy the sqlite database here and migrate the copy, leaving -db untouched")
+	status := fs.Bool("status", false, "print the schema version and exit")
+	fs.Parse(args)
 
-	http.HandleFunc("/", api.SearchForm)
-	http.HandleFunc("/search", api.Search)
-	http.HandleFunc("/results", api.Results)
-	http.HandleFunc("/about", api.About)
+	target := *dbPath
+	if *out != "" && !*status {
+		// only sqlite databases are files that can be copied
+		src := db.SQLitePath(*dbPath)
+		if src == "" {
+			return errors.New("-out only works with sqlite database files, not postgres")
+		}
+		if err := copyFile(src, *out); err != nil {
+			return err
+		}
+		target = *out
+	}
 
-	err = http.ListenAndServe(fmt.Sprintf(":%s", port), nil) // setting listening port
+	o, err := db.OpenDB(target)
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+
+	if *status {
+		v, err := o.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%v: schema version %d, latest %d\n", target, v, db.LatestVersion())
+		return nil
+	}
 
+	applied, err := o.Migrate()
 	if err != nil {
-		log.Fatal("ListenAndServe: ", err)
+		return err
+	}
+	log.Printf("applied %d migrations to %v, now at version %d\n", applied, target, db.LatestVersion())
+	return nil
+}
+
+// parseLifter splits "Name|Hometown" as given to the lifters command.
+func parseLifter(s string) (db.Lifter, error) {
+	parts := strings.SplitN(s, "|", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return db.Lifter{}, fmt.Errorf("%q should look like \"Name|Hometown\"", s)
+	}
+	return db.Lifter{Name: parts[0], Hometown: parts[1]}, nil
+}
+
+// runLifters manages lifter identities. With no flags it lists confirmed
+// merges and proposes new ones, -merge with -into confirms one and -split
+// undoes one.
+func runLifters(args []string) error {
+	fs := flag.NewFlagSet("lifters", flag.ExitOnError)
+	dbPath := fs.String("db", "./results.db", "sqlite database or postgres:// URL to manage")
+	merge := fs.String("merge", "", "merge this \"Name|Hometown\" into -into")
+	into := fs.String("into", "", "the canonical \"Name|Hometown\" for -merge")
+	split := fs.String("split", "", "undo the merge of this \"Name|Hometown\"")
+	fs.Parse(args)
+
+	o, err := db.BuildDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+
+	switch {
+	case *merge != "":
+		alias, err := parseLifter(*merge)
+		if err != nil {
+			return err
+		}
+		canonical, err := parseLifter(*into)
+		if err != nil {
+			return err
+		}
+		if err := o.MergeLifters(alias, canonical); err != nil {
+			return err
+		}
+		log.Printf("merged %v|%v into %v|%v\n", alias.Name, alias.Hometown, canonical.Name, canonical.Hometown)
+	case *split != "":
+		alias, err := parseLifter(*split)
+		if err != nil {
+			return err
+		}
+		if err := o.SplitLifter(alias); err != nil {
+			return err
+		}
+		log.Printf("split %v|%v\n", alias.Name, alias.Hometown)
+	default:
+		aliases, err := o.Aliases()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d merged lifters\n", len(aliases))
+		for _, a := range aliases {
+			fmt.Printf("  %v|%v -> %v|%v\n", a.Alias.Name, a.Alias.Hometown, a.Canonical.Name, a.Canonical.Hometown)
+		}
+		proposals, err := o.ProposeMerges()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d proposed merges, confirm with -merge ALIAS -into CANONICAL\n", len(proposals))
+		for _, p := range proposals {
+			fmt.Printf("  -merge %q -into %q\n      %v\n", p.Alias.Name+"|"+p.Alias.Hometown, p.Canonical.Name+"|"+p.Canonical.Hometown, p.Reason)
+		}
+	}
+	return nil
+}
+
+// runValidate reports data problems across every result in the database.
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	dbPath := fs.String("db", "./results.db", "sqlite database or postgres:// URL to check")
+	examples := fs.Int("examples", 5, "flagged results to print for each problem")
+	fs.Parse(args)
+
+	o, err := db.BuildDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+
+	report, err := o.Validate(*examples)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("checked %d results, %d flagged\n", report.Checked, report.Flagged)
+	for _, f := range db.Flags {
+		if report.Counts[f] == 0 {
+			continue
+		}
+		fmt.Printf("\n%v: %d (%v)\n", f, report.Counts[f], f.Description())
+		for _, r := range report.Examples[f] {
+			fmt.Printf("  %v %v: %v|%v %v @ %v, SN %v/%v/%v CJ %v/%v/%v, best %v/%v total %v\n",
+				r.Date, r.MeetName, r.Lifter, r.Hometown, r.Weightclass, r.CompetitionWeight,
+				r.SN1, r.SN2, r.SN3, r.CJ1, r.CJ2, r.CJ3, r.BestSN, r.BestCJ, r.Total)
+		}
+	}
+	return nil
+}
+
+// copyFile copies src to dst, refusing to overwrite an existing file.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
 	}
+	return out.Close()
 }
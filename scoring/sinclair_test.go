@@ -0,0 +1,71 @@
+package scoring
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenderFromWeightClass(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected Gender
+	}{
+		{"Women's 58Kg", Female},
+		{"Men's 77Kg", Male},
+		{"Womens +90Kg", Female},
+		{"Youth Men's 56Kg", Male},
+		{"W 64", Female},
+		{"M 105+", Male},
+		{"77", Unknown},
+		{"", Unknown},
+	}
+	for _, tt := range cases {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.expected, GenderFromWeightClass(tt.in))
+		})
+	}
+}
+
+func TestCoefficientsFor(t *testing.T) {
+	c, ok := CoefficientsFor("2018-06-20", Male)
+	assert.True(t, ok)
+	assert.Equal(t, 175.508, c.B)
+
+	c, ok = CoefficientsFor("2016-12-31", Female)
+	assert.True(t, ok)
+	assert.Equal(t, 148.026, c.B)
+
+	// older than the oldest table
+	c, ok = CoefficientsFor("2001-01-01", Male)
+	assert.True(t, ok)
+	assert.Equal(t, 173.961, c.B)
+
+	_, ok = CoefficientsFor("2018-06-20", Unknown)
+	assert.False(t, ok)
+}
+
+func TestSinclair(t *testing.T) {
+	cases := []struct {
+		name                    string
+		total, bodyweight, date string
+		weightClass             string
+		expected                string
+	}{
+		{"men 2017 cycle", "300", "77", "2018-01-01", "Men's 77Kg", "374.45"},
+		{"women 2017 cycle", "200", "63", "2019-03-02", "Women's 63Kg", "262.12"},
+		{"men 2013 cycle", "250", "85", "2014-05-05", "Men's 85Kg", "298.76"},
+		{"women 2009 cycle", "180", "58", "2010-05-05", "Women's 58Kg", "236.52"},
+		{"heavier than b", "400", "180", "2018-01-01", "Men's +105Kg", "400"},
+		{"unknown gender", "300", "77", "2018-01-01", "77", "0"},
+		{"no bodyweight", "300", "0", "2018-01-01", "Men's 77Kg", "0"},
+		{"bombed out", "0", "77", "2018-01-01", "Men's 77Kg", "0"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := Sinclair(decimal.RequireFromString(tt.total), decimal.RequireFromString(tt.bodyweight), tt.date, tt.weightClass)
+			assert.True(t, decimal.RequireFromString(tt.expected).Equal(actual), "expected %v got %v", tt.expected, actual)
+		})
+	}
+}
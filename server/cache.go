@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gitlab.com/derwolfe/faststats/cache"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+// cachedStore keeps recent lifter searches and results pages in memory. The
+// cache is emptied whenever the sqlite file changes, so an import or a
+// swapped in database is served straight away. Postgres databases are only
+// refreshed when entries expire.
+type cachedStore struct {
+	db.Store
+	names, results *cache.LRU
+	lookups        *prometheus.CounterVec
+	// path is the sqlite file watched for changes, empty for postgres
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+func newCachedStore(store db.Store, cfg Config, lookups *prometheus.CounterVec) *cachedStore {
+	s := &cachedStore{
+		Store:   store,
+		names:   cache.New(cfg.CacheSize, cfg.CacheTTL),
+		results: cache.New(cfg.CacheSize, cfg.CacheTTL),
+		lookups: lookups,
+		path:    db.SQLitePath(cfg.DatabaseURL),
+	}
+	s.modTime = s.stat()
+	return s
+}
+
+func (s *cachedStore) stat() time.Time {
+	if s.path == "" {
+		return time.Time{}
+	}
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// invalidate empties the caches if the database file has changed.
+func (s *cachedStore) invalidate() {
+	if s.path == "" {
+		return
+	}
+	modTime := s.stat()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if modTime.Equal(s.modTime) {
+		return
+	}
+	s.modTime = modTime
+	s.names.Purge()
+	s.results.Purge()
+}
+
+func (s *cachedStore) get(c *cache.LRU, name, key string) (interface{}, bool) {
+	s.invalidate()
+	v, ok := c.Get(key)
+	result := "miss"
+	if ok {
+		result = "hit"
+	}
+	s.lookups.WithLabelValues(name, result).Inc()
+	return v, ok
+}
+
+func (s *cachedStore) QueryNames(name, offset string) (*db.LiftersResponse, error) {
+	return s.lifters("page\x00"+name+"\x00"+offset, func() (*db.LiftersResponse, error) {
+		return s.Store.QueryNames(name, offset)
+	})
+}
+
+func (s *cachedStore) QueryNamesAfter(name, cursor string) (*db.LiftersResponse, error) {
+	return s.lifters("cursor\x00"+name+"\x00"+cursor, func() (*db.LiftersResponse, error) {
+		return s.Store.QueryNamesAfter(name, cursor)
+	})
+}
+
+func (s *cachedStore) lifters(key string, query func() (*db.LiftersResponse, error)) (*db.LiftersResponse, error) {
+	if v, ok := s.get(s.names, "names", key); ok {
+		return v.(*db.LiftersResponse), nil
+	}
+	found, err := query()
+	if err != nil {
+		return nil, err
+	}
+	s.names.Add(key, found)
+	return found, nil
+}
+
+func (s *cachedStore) QueryResults(name, hometown string) (*db.ResultsSummary, error) {
+	key := name + "\x00" + hometown
+	if v, ok := s.get(s.results, "results", key); ok {
+		return v.(*db.ResultsSummary), nil
+	}
+	found, err := s.Store.QueryResults(name, hometown)
+	if err != nil {
+		return nil, err
+	}
+	s.results.Add(key, found)
+	return found, nil
+}
+
+// bufferedResponse holds a response back so its ETag can be computed.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// etag tags successful GET responses with a hash of their body and answers
+// 304 Not Modified when the client already has it.
+func etag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		b := &bufferedResponse{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(b, r)
+		if b.status == http.StatusOK {
+			sum := sha1.Sum(b.body.Bytes())
+			tag := `"` + hex.EncodeToString(sum[:]) + `"`
+			w.Header().Set("ETag", tag)
+			if etagMatches(r.Header.Get("If-None-Match"), tag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.WriteHeader(b.status)
+		w.Write(b.body.Bytes())
+	})
+}
+
+// etagMatches reports whether an If-None-Match header lists tag. Weak tags
+// match, as they do for GET requests.
+func etagMatches(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == tag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
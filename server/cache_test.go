@@ -0,0 +1,112 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+// countingStore counts the queries that reach the database.
+type countingStore struct {
+	db.Store
+	results int
+}
+
+func (s *countingStore) QueryResults(name, hometown string) (*db.ResultsSummary, error) {
+	s.results++
+	return s.Store.QueryResults(name, hometown)
+}
+
+func TestCachedStore(t *testing.T) {
+	fixtures, err := db.LoadFixtures("../db/testdata/fixtures.jsonl")
+	assert.Nil(t, err)
+	defer fixtures.Close()
+
+	dir, err := ioutil.TempDir("", "faststats-cache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.db")
+	assert.Nil(t, ioutil.WriteFile(path, nil, 0644))
+
+	cfg := DefaultConfig()
+	cfg.DatabaseURL = path + "?_query_only=1"
+	counting := &countingStore{Store: fixtures}
+	m := newMetrics()
+	s := newCachedStore(counting, cfg, m.cacheLookups)
+
+	first, err := s.QueryResults("Chris Wolfe", "Austin, TX")
+	assert.Nil(t, err)
+	second, err := s.QueryResults("Chris Wolfe", "Austin, TX")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, counting.results, "the second lookup was cached")
+	assert.True(t, first == second)
+
+	_, err = s.QueryResults("Kyle Brown", "Reno, NV")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, counting.results, "lifters are cached separately")
+
+	// an import touches the database file
+	later := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(path, later, later))
+	_, err = s.QueryResults("Chris Wolfe", "Austin, TX")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, counting.results, "the cache was emptied when the file changed")
+
+	_, body := serve(t, m.handler(), "/metrics")
+	assert.Contains(t, body, `faststats_cache_lookups_total{cache="results",result="hit"} 1`)
+	assert.Contains(t, body, `faststats_cache_lookups_total{cache="results",result="miss"} 3`)
+}
+
+func TestCachedStoreDoesNotCacheErrors(t *testing.T) {
+	fixtures, err := db.LoadFixtures("../db/testdata/fixtures.jsonl")
+	assert.Nil(t, err)
+	s := newCachedStore(fixtures, DefaultConfig(), prometheus.NewCounterVec(prometheus.CounterOpts{Name: "lookups"}, []string{"cache", "result"}))
+	fixtures.Close()
+
+	_, err = s.QueryNames("wolfe", "")
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, s.names.Len())
+}
+
+func TestETag(t *testing.T) {
+	fixtures, err := db.LoadFixtures("../db/testdata/fixtures.jsonl")
+	assert.Nil(t, err)
+	defer fixtures.Close()
+	h := New(DefaultConfig(), fixtures).http.Handler
+
+	url := "/api/v1/lifters/results?name=Chris+Wolfe&hometown=Austin%2C+TX"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	tag := rec.Header().Get("ETag")
+	assert.NotEqual(t, "", tag)
+	body := rec.Body.String()
+
+	req := httptest.NewRequest("GET", url, nil)
+	req.Header.Set("If-None-Match", tag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, "", rec.Body.String())
+
+	req = httptest.NewRequest("GET", url, nil)
+	req.Header.Set("If-None-Match", `"stale", W/"other"`)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, body, rec.Body.String())
+
+	// errors aren't tagged
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/lifters/results?name=Nobody&hometown=Nowhere", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "", rec.Header().Get("ETag"))
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Level controls how much the server logs.
+type Level int
+
+const (
+	// LevelDebug logs every request and query.
+	LevelDebug Level = iota
+	// LevelInfo logs startup, shutdown and errors.
+	LevelInfo
+	// LevelError only logs errors.
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	}
+	return "error"
+}
+
+// ParseLevel converts a log level name.
+func ParseLevel(s string) (Level, error) {
+	switch s {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q, use debug, info or error", s)
+}
+
+// Config is everything needed to run the server. It is loaded from defaults,
+// then a JSON config file, then environment variables, then flags, each
+// overriding the last.
+type Config struct {
+	Addr        string
+	DatabaseURL string
+	// TLSCert and TLSKey serve HTTPS when both are set
+	TLSCert  string
+	TLSKey   string
+	LogLevel Level
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownTimeout bounds how long in flight requests are given to finish
+	ShutdownTimeout time.Duration
+
+	// CacheSize is how many query results are cached, zero disables caching
+	CacheSize int
+	CacheTTL  time.Duration
+}
+
+// DefaultConfig serves the read only sqlite database in the working
+// directory on port 8080.
+func DefaultConfig() Config {
+	return Config{
+		Addr:            ":8080",
+		DatabaseURL:     "./results.db",
+		LogLevel:        LevelInfo,
+		ReadTimeout:     5 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		IdleTimeout:     120 * time.Second,
+		ShutdownTimeout: 30 * time.Second,
+		CacheSize:       1000,
+		CacheTTL:        10 * time.Minute,
+	}
+}
+
+// settings are the config values as strings, the form they take in files,
+// environment variables and flags. Empty values are unset.
+type settings struct {
+	Addr            string `json:"addr"`
+	DatabaseURL     string `json:"database_url"`
+	TLSCert         string `json:"tls_cert"`
+	TLSKey          string `json:"tls_key"`
+	LogLevel        string `json:"log_level"`
+	ReadTimeout     string `json:"read_timeout"`
+	WriteTimeout    string `json:"write_timeout"`
+	IdleTimeout     string `json:"idle_timeout"`
+	ShutdownTimeout string `json:"shutdown_timeout"`
+	CacheSize       string `json:"cache_size"`
+	CacheTTL        string `json:"cache_ttl"`
+}
+
+func (c *Config) apply(s settings) error {
+	for _, v := range []struct {
+		value string
+		dst   *string
+	}{
+		{s.Addr, &c.Addr},
+		{s.DatabaseURL, &c.DatabaseURL},
+		{s.TLSCert, &c.TLSCert},
+		{s.TLSKey, &c.TLSKey},
+	} {
+		if v.value != "" {
+			*v.dst = v.value
+		}
+	}
+	if s.LogLevel != "" {
+		l, err := ParseLevel(s.LogLevel)
+		if err != nil {
+			return err
+		}
+		c.LogLevel = l
+	}
+	if s.CacheSize != "" {
+		n, err := strconv.Atoi(s.CacheSize)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid cache size %q, use a number of entries", s.CacheSize)
+		}
+		c.CacheSize = n
+	}
+	for _, v := range []struct {
+		name, value string
+		dst         *time.Duration
+	}{
+		{"read timeout", s.ReadTimeout, &c.ReadTimeout},
+		{"write timeout", s.WriteTimeout, &c.WriteTimeout},
+		{"idle timeout", s.IdleTimeout, &c.IdleTimeout},
+		{"shutdown timeout", s.ShutdownTimeout, &c.ShutdownTimeout},
+		{"cache ttl", s.CacheTTL, &c.CacheTTL},
+	} {
+		if v.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v.value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid %v %q, use a duration such as 30s", v.name, v.value)
+		}
+		*v.dst = d
+	}
+	return nil
+}
+
+func readConfigFile(path string) (settings, error) {
+	var s settings
+	f, err := os.Open(path)
+	if err != nil {
+		return s, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return s, fmt.Errorf("reading %v: %v", path, err)
+	}
+	return s, nil
+}
+
+func envSettings(getenv func(string) string) settings {
+	s := settings{
+		Addr:            getenv("FASTSTATS_ADDR"),
+		DatabaseURL:     getenv("DATABASE_URL"),
+		TLSCert:         getenv("FASTSTATS_TLS_CERT"),
+		TLSKey:          getenv("FASTSTATS_TLS_KEY"),
+		LogLevel:        getenv("FASTSTATS_LOG_LEVEL"),
+		ReadTimeout:     getenv("FASTSTATS_READ_TIMEOUT"),
+		WriteTimeout:    getenv("FASTSTATS_WRITE_TIMEOUT"),
+		IdleTimeout:     getenv("FASTSTATS_IDLE_TIMEOUT"),
+		ShutdownTimeout: getenv("FASTSTATS_SHUTDOWN_TIMEOUT"),
+		CacheSize:       getenv("FASTSTATS_CACHE_SIZE"),
+		CacheTTL:        getenv("FASTSTATS_CACHE_TTL"),
+	}
+	// PORT is what most hosts set
+	if port := getenv("PORT"); port != "" && s.Addr == "" {
+		s.Addr = ":" + port
+	}
+	return s
+}
+
+// LoadConfig builds a Config from the serve command's args and the
+// environment. A config file is read from -config or FASTSTATS_CONFIG.
+func LoadConfig(args []string, getenv func(string) string) (Config, error) {
+	var flags settings
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := fs.String("config", getenv("FASTSTATS_CONFIG"), "JSON config file, keys match the flag names with underscores")
+	fs.StringVar(&flags.Addr, "addr", "", "address to listen on, e.g. :8080 (env FASTSTATS_ADDR or PORT)")
+	fs.StringVar(&flags.DatabaseURL, "db", "", "sqlite database or postgres:// URL (env DATABASE_URL)")
+	fs.StringVar(&flags.TLSCert, "tls-cert", "", "TLS certificate file, serves HTTPS with -tls-key (env FASTSTATS_TLS_CERT)")
+	fs.StringVar(&flags.TLSKey, "tls-key", "", "TLS key file (env FASTSTATS_TLS_KEY)")
+	fs.StringVar(&flags.LogLevel, "log-level", "", "debug, info or error (env FASTSTATS_LOG_LEVEL)")
+	fs.StringVar(&flags.ReadTimeout, "read-timeout", "", "time allowed to read a request (env FASTSTATS_READ_TIMEOUT)")
+	fs.StringVar(&flags.WriteTimeout, "write-timeout", "", "time allowed to write a response (env FASTSTATS_WRITE_TIMEOUT)")
+	fs.StringVar(&flags.IdleTimeout, "idle-timeout", "", "time keep alive connections are held open (env FASTSTATS_IDLE_TIMEOUT)")
+	fs.StringVar(&flags.ShutdownTimeout, "shutdown-timeout", "", "time in flight requests get to finish on shutdown (env FASTSTATS_SHUTDOWN_TIMEOUT)")
+	fs.StringVar(&flags.CacheSize, "cache-size", "", "query results to cache, 0 disables the cache (env FASTSTATS_CACHE_SIZE)")
+	fs.StringVar(&flags.CacheTTL, "cache-ttl", "", "time query results are cached for (env FASTSTATS_CACHE_TTL)")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	c := DefaultConfig()
+	if *configPath != "" {
+		file, err := readConfigFile(*configPath)
+		if err != nil {
+			return c, err
+		}
+		if err := c.apply(file); err != nil {
+			return c, err
+		}
+	}
+	if err := c.apply(envSettings(getenv)); err != nil {
+		return c, err
+	}
+	if err := c.apply(flags); err != nil {
+		return c, err
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return c, fmt.Errorf("both a TLS certificate and key are needed to serve HTTPS")
+	}
+	return c, nil
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	c, err := LoadConfig(nil, env(nil))
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultConfig(), c)
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "faststats-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "faststats.json")
+	file := `{"addr": ":7000", "database_url": "file.db", "log_level": "error", "read_timeout": "1s", "idle_timeout": "1m", "cache_size": "50"}`
+	assert.Nil(t, ioutil.WriteFile(path, []byte(file), 0644))
+
+	c, err := LoadConfig([]string{"-read-timeout", "3s", "-cache-ttl", "30s"}, env(map[string]string{
+		"FASTSTATS_CONFIG": path,
+		"PORT":             "9000",
+		"DATABASE_URL":     "env.db",
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, ":9000", c.Addr, "env overrides the file")
+	assert.Equal(t, "env.db", c.DatabaseURL)
+	assert.Equal(t, LevelError, c.LogLevel, "the file overrides defaults")
+	assert.Equal(t, 3*time.Second, c.ReadTimeout, "flags override everything")
+	assert.Equal(t, time.Minute, c.IdleTimeout)
+	assert.Equal(t, 50, c.CacheSize)
+	assert.Equal(t, 30*time.Second, c.CacheTTL)
+
+	c, err = LoadConfig([]string{"-config", path, "-addr", ":1234"}, env(map[string]string{"PORT": "9000"}))
+	assert.Nil(t, err)
+	assert.Equal(t, ":1234", c.Addr)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+	}{
+		{"bad level", []string{"-log-level", "loud"}},
+		{"bad timeout", []string{"-write-timeout", "soon"}},
+		{"bad cache size", []string{"-cache-size", "-1"}},
+		{"cert without key", []string{"-tls-cert", "cert.pem"}},
+		{"missing file", []string{"-config", "/nonexistent/faststats.json"}},
+		{"extra args", []string{"results.db"}},
+	}
+	for _, c := range cases {
+		_, err := LoadConfig(c.args, env(nil))
+		assert.NotNil(t, err, c.name)
+	}
+}
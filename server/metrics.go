@@ -0,0 +1,150 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+// metrics are exposed on /metrics. Each server has its own registry so tests
+// can build several.
+type metrics struct {
+	registry     *prometheus.Registry
+	requests     *prometheus.CounterVec
+	latency      *prometheus.HistogramVec
+	queries      *prometheus.HistogramVec
+	renderErrors *prometheus.CounterVec
+	cacheLookups *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "faststats",
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by handler, method and status code.",
+		}, []string{"handler", "method", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "faststats",
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests by handler.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"handler"}),
+		queries: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "faststats",
+			Name:      "db_query_duration_seconds",
+			Help:      "Time taken by database queries by query.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"query"}),
+		renderErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "faststats",
+			Name:      "template_render_errors_total",
+			Help:      "Page templates that failed to render by page.",
+		}, []string{"page"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "faststats",
+			Name:      "cache_lookups_total",
+			Help:      "Query cache lookups by cache and result, hit or miss.",
+		}, []string{"cache", "result"}),
+	}
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.requests, m.latency, m.queries, m.renderErrors, m.cacheLookups,
+	)
+	return m
+}
+
+// instrument counts and times every request to h under name.
+func (m *metrics) instrument(name string, h http.Handler) http.Handler {
+	labels := prometheus.Labels{"handler": name}
+	return promhttp.InstrumentHandlerDuration(m.latency.MustCurryWith(labels),
+		promhttp.InstrumentHandlerCounter(m.requests.MustCurryWith(labels), h))
+}
+
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *metrics) renderError(page string, err error) {
+	m.renderErrors.WithLabelValues(page).Inc()
+}
+
+// timedStore records how long each query takes.
+type timedStore struct {
+	db.Store
+	queries *prometheus.HistogramVec
+}
+
+func (s timedStore) observe(query string, start time.Time) {
+	s.queries.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
+
+func (s timedStore) QueryNames(name, offset string) (*db.LiftersResponse, error) {
+	defer s.observe("QueryNames", time.Now())
+	return s.Store.QueryNames(name, offset)
+}
+
+func (s timedStore) QueryNamesAfter(name, cursor string) (*db.LiftersResponse, error) {
+	defer s.observe("QueryNamesAfter", time.Now())
+	return s.Store.QueryNamesAfter(name, cursor)
+}
+
+func (s timedStore) QueryResults(name, hometown string) (*db.ResultsSummary, error) {
+	defer s.observe("QueryResults", time.Now())
+	return s.Store.QueryResults(name, hometown)
+}
+
+func (s timedStore) QueryMeets(f db.MeetFilter, offset string) (*db.MeetsResponse, error) {
+	defer s.observe("QueryMeets", time.Now())
+	return s.Store.QueryMeets(f, offset)
+}
+
+func (s timedStore) QueryMeet(name, date string) (*db.MeetResults, error) {
+	defer s.observe("QueryMeet", time.Now())
+	return s.Store.QueryMeet(name, date)
+}
+
+func (s timedStore) QueryRankings(f db.RankingFilter, offset string) (*db.RankingsResponse, error) {
+	defer s.observe("QueryRankings", time.Now())
+	return s.Store.QueryRankings(f, offset)
+}
+
+func (s timedStore) QueryRecords(f db.RecordFilter) (*db.RecordsResponse, error) {
+	defer s.observe("QueryRecords", time.Now())
+	return s.Store.QueryRecords(f)
+}
+
+func (s timedStore) Suggest(prefix string, limit int) (*db.SuggestResponse, error) {
+	defer s.observe("Suggest", time.Now())
+	return s.Store.Suggest(prefix, limit)
+}
+
+func (s timedStore) QueryHometowns(name, offset string) (*db.HometownsResponse, error) {
+	defer s.observe("QueryHometowns", time.Now())
+	return s.Store.QueryHometowns(name, offset)
+}
+
+func (s timedStore) QueryHometown(name string) (*db.HometownResults, error) {
+	defer s.observe("QueryHometown", time.Now())
+	return s.Store.QueryHometown(name)
+}
+
+// healthz reports whether the database can be read. Load balancers should
+// take the server out of rotation when it fails.
+func healthz(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := store.Ping(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("503 - database unavailable: " + err.Error()))
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
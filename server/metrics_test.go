@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+type downStore struct {
+	db.Store
+}
+
+func (downStore) Ping() error {
+	return errors.New("disk on fire")
+}
+
+func serve(t *testing.T, h http.Handler, url string) (int, string) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+	body, err := ioutil.ReadAll(rec.Body)
+	assert.Nil(t, err)
+	return rec.Code, string(body)
+}
+
+func TestHealthz(t *testing.T) {
+	fixtures, err := db.LoadFixtures("../db/testdata/fixtures.jsonl")
+	assert.Nil(t, err)
+	defer fixtures.Close()
+
+	code, body := serve(t, New(DefaultConfig(), fixtures).http.Handler, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", body)
+
+	code, body = serve(t, New(DefaultConfig(), downStore{fixtures}).http.Handler, "/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "disk on fire")
+}
+
+func TestMetrics(t *testing.T) {
+	fixtures, err := db.LoadFixtures("../db/testdata/fixtures.jsonl")
+	assert.Nil(t, err)
+	defer fixtures.Close()
+	h := New(DefaultConfig(), fixtures).http.Handler
+
+	code, _ := serve(t, h, "/search?name=wolfe")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = serve(t, h, "/results?name=Chris+Wolfe&hometown=Austin%2C+TX")
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body := serve(t, h, "/metrics")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `faststats_http_requests_total{code="200",handler="Search",method="get"} 1`)
+	assert.Contains(t, body, `faststats_http_requests_total{code="200",handler="Results",method="get"} 1`)
+	assert.Contains(t, body, `faststats_http_request_duration_seconds_count{handler="Results"} 1`)
+	assert.Contains(t, body, `faststats_db_query_duration_seconds_count{query="QueryNames"} 1`)
+	assert.Contains(t, body, `faststats_db_query_duration_seconds_count{query="QueryResults"} 1`)
+}
+
+func TestMetricsRenderErrors(t *testing.T) {
+	m := newMetrics()
+	m.renderError("liftingResults", errors.New("boom"))
+
+	_, body := serve(t, m.handler(), "/metrics")
+	assert.Contains(t, body, `faststats_template_render_errors_total{page="liftingResults"} 1`)
+}
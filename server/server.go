@@ -0,0 +1,171 @@
+// Package server runs the faststats website and JSON API.
+package server
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"gitlab.com/derwolfe/faststats/api"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+// Server serves a Store over HTTP until its context is cancelled.
+type Server struct {
+	cfg   Config
+	store db.Store
+	http  *http.Server
+}
+
+// route is a page or API endpoint. Name labels its metrics.
+type route struct {
+	path, name string
+	handler    http.HandlerFunc
+}
+
+func routes(a *api.API) []route {
+	return []route{
+		{"/", "SearchForm", a.SearchForm},
+		{"/search", "Search", a.Search},
+		{"/results", "Results", a.Results},
+		{"/results/chart.svg", "ResultsChart", a.ResultsChart},
+		{"/results.csv", "ResultsCSV", a.ResultsCSV},
+		{"/results.json", "ResultsExportJSON", a.ResultsExportJSON},
+		{"/about", "About", a.About},
+		{"/meets", "Meets", a.Meets},
+		{"/meet", "Meet", a.Meet},
+		{"/rankings", "Rankings", a.Rankings},
+		{"/compare", "Compare", a.Compare},
+		{"/records", "Records", a.Records},
+		{"/hometowns", "Hometowns", a.Hometowns},
+		{"/hometown", "Hometown", a.Hometown},
+		{"/api/v1/lifters", "LiftersJSON", a.LiftersJSON},
+		{"/api/v1/lifters/results", "LifterResultsJSON", a.LifterResultsJSON},
+		{"/api/v1/lifters/prs", "LifterPRsJSON", a.LifterPRsJSON},
+		{"/api/v1/meets", "MeetsJSON", a.MeetsJSON},
+		{"/api/v1/meet", "MeetJSON", a.MeetJSON},
+		{"/api/v1/rankings", "RankingsJSON", a.RankingsJSON},
+		{"/api/v1/compare", "CompareJSON", a.CompareJSON},
+		{"/api/v1/records", "RecordsJSON", a.RecordsJSON},
+		{"/api/v1/suggest", "SuggestJSON", a.SuggestJSON},
+		{"/api/v1/hometowns", "HometownsJSON", a.HometownsJSON},
+		{"/api/v1/hometown", "HometownJSON", a.HometownJSON},
+	}
+}
+
+// statusRecorder remembers the status code written for the access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// accessLog logs every request, it is only installed at debug level.
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("%v %v %d %v\n", r.Method, r.URL.RequestURI(), rec.status, time.Since(start))
+	})
+}
+
+// OpenStore opens the database in cfg for serving. Sqlite databases are
+// opened read only whatever DSN they are given, if we get SQLi this should
+// limit damage.
+func OpenStore(cfg Config) (*db.OurDB, error) {
+	return db.BuildDB(db.ReadOnly(cfg.DatabaseURL))
+}
+
+// New builds a server for store. The store is closed when the server stops.
+func New(cfg Config, store db.Store) *Server {
+	db.SetQueryLogging(cfg.LogLevel == LevelDebug)
+	m := newMetrics()
+	var queries db.Store = timedStore{Store: store, queries: m.queries}
+	if cfg.CacheSize > 0 {
+		queries = newCachedStore(queries, cfg, m.cacheLookups)
+	}
+	a := api.NewAPI(queries)
+	a.OnRenderError(m.renderError)
+
+	mux := http.NewServeMux()
+	for _, rt := range routes(a) {
+		mux.Handle(rt.path, m.instrument(rt.name, etag(rt.handler)))
+	}
+	mux.Handle("/healthz", healthz(store))
+	mux.Handle("/metrics", m.handler())
+
+	var handler http.Handler = mux
+	if cfg.LogLevel == LevelDebug {
+		handler = accessLog(handler)
+	}
+	return &Server{
+		cfg:   cfg,
+		store: store,
+		http: &http.Server{
+			Addr:         cfg.Addr,
+			Handler:      handler,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+		},
+	}
+}
+
+func (s *Server) infof(format string, v ...interface{}) {
+	if s.cfg.LogLevel <= LevelInfo {
+		log.Printf(format, v...)
+	}
+}
+
+// Run listens on the configured address and serves until ctx is done.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		s.store.Close()
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx is done, then stops accepting
+// new requests, waits up to the shutdown timeout for in flight requests to
+// finish and closes the store.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	defer s.store.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		if s.cfg.TLSCert != "" {
+			s.infof("serving https on %v\n", ln.Addr())
+			errc <- s.http.ServeTLS(ln, s.cfg.TLSCert, s.cfg.TLSKey)
+			return
+		}
+		s.infof("serving http on %v\n", ln.Addr())
+		errc <- s.http.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.infof("shutting down, waiting up to %v for requests to finish\n", s.cfg.ShutdownTimeout)
+	sctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	if err := s.http.Shutdown(sctx); err != nil {
+		return err
+	}
+	if err := <-errc; err != http.ErrServerClosed {
+		return err
+	}
+	s.infof("stopped\n")
+	return nil
+}
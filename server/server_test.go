@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/db"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// closeStore records whether the server closed its store.
+type closeStore struct {
+	db.Store
+	closed chan struct{}
+}
+
+func (s *closeStore) Close() {
+	s.Store.Close()
+	close(s.closed)
+}
+
+func TestServeShutsDownGracefully(t *testing.T) {
+	fixtures, err := db.LoadFixtures("../db/testdata/fixtures.jsonl")
+	assert.Nil(t, err)
+	store := &closeStore{Store: fixtures, closed: make(chan struct{})}
+
+	// the default timeout is well past net/http's grace for new connections
+	s := New(DefaultConfig(), store)
+
+	// hold a request open across the shutdown
+	started, release := make(chan struct{}), make(chan struct{})
+	inner := s.http.Handler
+	s.http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		inner.ServeHTTP(w, r)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+
+	// a connection left idle in a shared pool could be closed under the test
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	base := "http://" + ln.Addr().String()
+	resp, err := client.Get(base + "/api/v1/meets")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	slow := make(chan int, 1)
+	go func() {
+		resp, err := client.Get(base + "/slow")
+		if err != nil {
+			slow <- 0
+			return
+		}
+		resp.Body.Close()
+		slow <- resp.StatusCode
+	}()
+	<-started
+	cancel()
+
+	select {
+	case <-store.closed:
+		t.Fatal("the store was closed with a request in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	assert.Equal(t, http.StatusOK, <-slow, "the in flight request finished")
+	assert.Nil(t, <-done)
+	<-store.closed
+}
+
+func TestOpenStoreIsReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "faststats-server")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "custom.db")
+
+	o, err := db.OpenDB(path)
+	assert.Nil(t, err)
+	_, err = o.Migrate()
+	assert.Nil(t, err)
+	o.Close()
+
+	// a custom path without _query_only is still served read only
+	cfg := DefaultConfig()
+	cfg.DatabaseURL = path
+	store, err := OpenStore(cfg)
+	assert.Nil(t, err)
+	defer store.Close()
+	assert.Nil(t, store.Ping())
+	assert.NotNil(t, store.UpsertResults([]*db.Result{{Lifter: "Chris Wolfe", Hometown: "Austin, TX", URL: "https://example.com/meet"}}), "the store accepted a write")
+}
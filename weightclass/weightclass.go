@@ -0,0 +1,187 @@
+// Package weightclass models IWF bodyweight categories and maps results
+// between the categories used before and after the 2018 reclassification.
+package weightclass
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"gitlab.com/derwolfe/faststats/scoring"
+)
+
+// Era is a set of bodyweight categories in use over a period of time.
+type Era int
+
+const (
+	EraUnknown Era = iota
+	// Era1998 categories were used until the 2018 reclassification. The
+	// women's 90 and +90 categories were added to it in 2017.
+	Era1998
+	// Era2018 categories replaced them in mid 2018.
+	Era2018
+)
+
+// Modern is the era results are mapped into for comparisons over time.
+const Modern = Era2018
+
+func (e Era) String() string {
+	switch e {
+	case Era1998:
+		return "1998"
+	case Era2018:
+		return "2018"
+	}
+	return "unknown"
+}
+
+// era2018Start is the first day USAW results used the 2018 categories. Only
+// used for classes whose limit doesn't identify the era.
+const era2018Start = "2018-06-01"
+
+// limits are the upper limits of each era's categories, the heaviest
+// category is unlimited above the last one.
+var limits = map[Era]map[scoring.Gender][]float64{
+	Era1998: {
+		scoring.Male:   {56, 62, 69, 77, 85, 94, 105},
+		scoring.Female: {48, 53, 58, 63, 69, 75, 90},
+	},
+	Era2018: {
+		scoring.Male:   {55, 61, 67, 73, 81, 89, 96, 102, 109},
+		scoring.Female: {45, 49, 55, 59, 64, 71, 76, 81, 87},
+	},
+}
+
+// Class is a bodyweight category.
+type Class struct {
+	Gender scoring.Gender
+	// Limit is the upper limit, or the floor of an unlimited class
+	Limit     float64
+	Unlimited bool
+	Era       Era
+}
+
+func (c Class) String() string {
+	g := "Men's"
+	if c.Gender == scoring.Female {
+		g = "Women's"
+	}
+	plus := ""
+	if c.Unlimited {
+		plus = "+"
+	}
+	return fmt.Sprintf("%s %s%sKg", g, plus, strconv.FormatFloat(c.Limit, 'f', -1, 64))
+}
+
+// Contains reports whether a lifter weighing bodyweight could compete in c.
+func (c Class) Contains(bodyweight float64) bool {
+	if c.Unlimited {
+		return bodyweight > c.Limit
+	}
+	return bodyweight <= c.Limit && bodyweight > c.Floor()
+}
+
+// Floor is the limit of the next lighter class in the era, zero for the
+// lightest class or a class outside any known era.
+func (c Class) Floor() float64 {
+	if c.Unlimited {
+		return c.Limit
+	}
+	floor := 0.0
+	for _, l := range limits[c.Era][c.Gender] {
+		if l >= c.Limit {
+			break
+		}
+		floor = l
+	}
+	return floor
+}
+
+// Classes returns every category for a gender in an era, lightest first.
+func Classes(g scoring.Gender, e Era) []Class {
+	ls := limits[e][g]
+	if len(ls) == 0 {
+		return nil
+	}
+	classes := make([]Class, 0, len(ls)+1)
+	for _, l := range ls {
+		classes = append(classes, Class{Gender: g, Limit: l, Era: e})
+	}
+	return append(classes, Class{Gender: g, Limit: ls[len(ls)-1], Unlimited: true, Era: e})
+}
+
+var classReg = regexp.MustCompile(`(\+)?\s*(\d+(\.\d+)?)\s*(\+)?`)
+
+// Parse reads a weight class as written in results, e.g. "Men's 77Kg" or
+// "Women's +90Kg". The era comes from the limit, the two eras share no
+// limits for the same gender, falling back to date (YYYY-MM-DD) for classes
+// that aren't in either era such as youth categories.
+func Parse(weightClass, date string) (Class, error) {
+	c := Class{Gender: scoring.GenderFromWeightClass(weightClass)}
+	if c.Gender == scoring.Unknown {
+		return c, fmt.Errorf("no gender in weight class %q", weightClass)
+	}
+	m := classReg.FindStringSubmatch(weightClass)
+	if m == nil {
+		return c, fmt.Errorf("no limit in weight class %q", weightClass)
+	}
+	limit, err := strconv.ParseFloat(m[2], 64)
+	if err != nil {
+		return c, fmt.Errorf("no limit in weight class %q", weightClass)
+	}
+	c.Limit = limit
+	c.Unlimited = m[1] != "" || m[4] != ""
+
+	for _, e := range []Era{Era1998, Era2018} {
+		for _, known := range Classes(c.Gender, e) {
+			if known.Limit == c.Limit && known.Unlimited == c.Unlimited {
+				c.Era = e
+				return c, nil
+			}
+		}
+	}
+	switch {
+	case date == "":
+	case date < era2018Start:
+		c.Era = Era1998
+	default:
+		c.Era = Era2018
+	}
+	return c, nil
+}
+
+// Map returns the category in era to that a lifter in c weighing bodyweight
+// would compete in. Without a bodyweight the class containing c's limit is
+// used, so old classes map to the nearest new class that fits everyone in
+// them by weight.
+func Map(c Class, bodyweight float64, to Era) Class {
+	if c.Era == to {
+		return c
+	}
+	classes := Classes(c.Gender, to)
+	if len(classes) == 0 {
+		return c
+	}
+	if bodyweight <= 0 {
+		bodyweight = c.Limit
+		if c.Unlimited {
+			// anything over the floor, nudge past it
+			bodyweight += 0.01
+		}
+	}
+	for _, n := range classes {
+		if n.Contains(bodyweight) {
+			return n
+		}
+	}
+	return classes[0]
+}
+
+// ToModern parses weightClass and maps it into the modern era.
+func ToModern(weightClass, date string, bodyweight float64) (Class, error) {
+	c, err := Parse(weightClass, date)
+	if err != nil {
+		return c, err
+	}
+	return Map(c, bodyweight, Modern), nil
+}
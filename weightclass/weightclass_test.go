@@ -0,0 +1,68 @@
+package weightclass
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/derwolfe/faststats/scoring"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		class, date string
+		want        Class
+	}{
+		{"Men's 77Kg", "2017-06-17", Class{Gender: scoring.Male, Limit: 77, Era: Era1998}},
+		{"Men's 81Kg", "2018-06-20", Class{Gender: scoring.Male, Limit: 81, Era: Era2018}},
+		{"Women's +90Kg", "", Class{Gender: scoring.Female, Limit: 90, Unlimited: true, Era: Era1998}},
+		{"Women's 87+ Kg", "", Class{Gender: scoring.Female, Limit: 87, Unlimited: true, Era: Era2018}},
+		{"Men's 50Kg", "2016-01-01", Class{Gender: scoring.Male, Limit: 50, Era: Era1998}},
+		{"Men's 50Kg", "", Class{Gender: scoring.Male, Limit: 50}},
+	}
+	for _, c := range cases {
+		got, err := Parse(c.class, c.date)
+		assert.Nil(t, err, c.class)
+		assert.Equal(t, c.want, got, c.class)
+	}
+
+	_, err := Parse("77Kg", "")
+	assert.NotNil(t, err, "a gender is required")
+	_, err = Parse("Men's", "")
+	assert.NotNil(t, err, "a limit is required")
+}
+
+func TestString(t *testing.T) {
+	for _, s := range []string{"Men's 77Kg", "Women's +87Kg", "Women's 45Kg"} {
+		c, err := Parse(s, "")
+		assert.Nil(t, err)
+		assert.Equal(t, s, c.String())
+	}
+}
+
+func TestFloor(t *testing.T) {
+	c, _ := Parse("Men's 81Kg", "")
+	assert.Equal(t, 73.0, c.Floor())
+	c, _ = Parse("Women's 45Kg", "")
+	assert.Equal(t, 0.0, c.Floor())
+	c, _ = Parse("Men's +109Kg", "")
+	assert.Equal(t, 109.0, c.Floor())
+}
+
+func TestMap(t *testing.T) {
+	old, _ := Parse("Men's 69Kg", "")
+	assert.Equal(t, "Men's 67Kg", Map(old, 66.8, Modern).String(), "bodyweight picks the class")
+	assert.Equal(t, "Men's 73Kg", Map(old, 68.2, Modern).String())
+	assert.Equal(t, "Men's 73Kg", Map(old, 0, Modern).String(), "without a bodyweight the limit has to fit")
+
+	heavy, _ := Parse("Men's +105Kg", "")
+	assert.Equal(t, "Men's 109Kg", Map(heavy, 107, Modern).String())
+	assert.Equal(t, "Men's +109Kg", Map(heavy, 112, Modern).String())
+	assert.Equal(t, "Men's 109Kg", Map(heavy, 0, Modern).String(), "the lightest lifter allowed in +105 fits 109")
+
+	modern, _ := Parse("Women's 64Kg", "")
+	assert.Equal(t, modern, Map(modern, 61, Modern), "classes already in the era are kept")
+
+	c, err := ToModern("Women's 63Kg", "2017-01-01", 62.5)
+	assert.Nil(t, err)
+	assert.Equal(t, "Women's 64Kg", c.String())
+}